@@ -0,0 +1,119 @@
+// Line diff algorithms.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// algorithmType describes a line diff algorithm.
+// Fct returns the match points between the interned sequences a and b.
+type algorithmType struct {
+	Name string
+	Desc string
+	Fct  func(a, b []int) [][]int
+}
+
+// algorithms is the list of the available line diff algorithms.
+// The first one is the default.
+var algorithms = []algorithmType{
+	{
+		Name: "myers",
+		Desc: "Myers O(ND) shortest edit script.",
+		Fct: func(a, b []int) [][]int {
+			return myersMatchPoints(a, b, 0, len(a), 0, len(b), [][]int{})
+		},
+	},
+	{
+		Name: "patience",
+		Desc: "Patience diff, anchored on lines that are unique in both files.",
+		Fct: func(a, b []int) [][]int {
+			return patienceMatchPoints(a, b, 0, len(a), 0, len(b), [][]int{})
+		},
+	},
+	{
+		Name: "histogram",
+		Desc: "Histogram diff, anchored on the least frequent common lines.",
+		Fct: func(a, b []int) [][]int {
+			return histogramMatchPoints(a, b, 0, len(a), 0, len(b), [][]int{})
+		},
+	},
+}
+
+// getAlgorithm returns the algorithm with the specified name.
+func getAlgorithm(name string) (algorithmType, bool) {
+	for _, algorithm := range algorithms {
+		if algorithm.Name == strings.ToLower(name) {
+			return algorithm, true
+		}
+	}
+	return algorithmType{}, false
+}
+
+// getAlgorithmNames returns the sorted list of algorithm names.
+func getAlgorithmNames() (names []string) {
+	for _, algorithm := range algorithms {
+		names = append(names, algorithm.Name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// matchPoints returns the match points between seq1 and seq2 using the
+// named algorithm.
+// The first entry of each match point is the line index in seq1 and the
+// second entry is the line index in seq2.
+func matchPoints(name string, seq1, seq2 []string) [][]int {
+	algorithm, _ := getAlgorithm(name)
+	a, b := internLines(seq1, seq2)
+	return algorithm.Fct(a, b)
+}
+
+// internLines maps each distinct line to a unique integer.
+func internLines(seq1, seq2 []string) (a, b []int) {
+	ids := map[string]int{}
+	intern := func(seq []string) []int {
+		r := make([]int, len(seq))
+		for i, line := range seq {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			r[i] = id
+		}
+		return r
+	}
+	a = intern(seq1)
+	b = intern(seq2)
+	return
+}
+
+// trimMatches finds the common prefix and suffix of the box
+// a[aLo:aHi], b[bLo:bHi]. The prefix match points are appended to mp.
+// It returns the remaining box and the length of the common suffix
+// which must be appended by the caller after the remaining box is
+// processed to keep the match points in order.
+func trimMatches(a, b []int, aLo, aHi, bLo, bHi int, mp [][]int) (int, int, int, int, int, [][]int) {
+	for aLo < aHi && bLo < bHi && a[aLo] == b[bLo] {
+		mp = append(mp, []int{aLo, bLo})
+		aLo++
+		bLo++
+	}
+	n := 0
+	for aLo < aHi-n && bLo < bHi-n && a[aHi-n-1] == b[bHi-n-1] {
+		n++
+	}
+	return aLo, aHi - n, bLo, bHi - n, n, mp
+}
+
+// appendMatches appends n consecutive match points starting at
+// a[i], b[j].
+func appendMatches(mp [][]int, i, j, n int) [][]int {
+	for k := 0; k < n; k++ {
+		mp = append(mp, []int{i + k, j + k})
+	}
+	return mp
+}
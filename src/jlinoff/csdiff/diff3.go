@@ -0,0 +1,293 @@
+// Three way diff against a common ancestor.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import "fmt"
+
+// Kinds of diff3 chunks.
+const (
+	chunkSame     = iota // the changes are ignored
+	chunkLeft            // only the left file changed
+	chunkRight           // only the right file changed
+	chunkBoth            // both files changed the same way
+	chunkConflict        // both files changed differently
+)
+
+// chunkType is a diff3 chunk, the lines base[B0:B1], left[L0:L1] and
+// right[R0:R1] between two lines that are stable in all three files.
+type chunkType struct {
+	Kind int
+	B0   int
+	B1   int
+	L0   int
+	L1   int
+	R0   int
+	R1   int
+}
+
+// diff3SummaryType is the diff3 summary information.
+type diff3SummaryType struct {
+	NumStableLines  int
+	NumLeftChanges  int
+	NumRightChanges int
+	NumBothChanges  int
+	NumConflicts    int
+}
+
+// diff3Init reads and filters the base, left and right files and finds
+// the chunks. The stable lines are the chunks with no lines.
+//
+// The base is compared to each file to find the match points. A base
+// line that matches a line in both files is stable, the lines between
+// the stable lines are a chunk. The base is filtered by the prefilter
+// command only if both files use the same one.
+func diff3Init(opts options) (base, left, right []lineType, chunks []chunkType) {
+	prefilter := ""
+	if opts.PrefilterLeft == opts.PrefilterRight {
+		prefilter = opts.PrefilterLeft
+	}
+	base = filter(opts, readInput(opts.Base, prefilter), []replaceType{})
+	left = filter(opts, readInput(opts.File1, opts.PrefilterLeft), opts.ReplacementsLeft)
+	right = filter(opts, readInput(opts.File2, opts.PrefilterRight), opts.ReplacementsRight)
+	mapL := baseMap(opts, base, left)
+	mapR := baseMap(opts, base, right)
+
+	// lambda to find out if two ranges of lines have the same
+	// normalized text, the ignored lines are skipped.
+	same := func(a []lineType, x0, x1 int, b []lineType, y0, y1 int) bool {
+		_, na := keptLines(a[x0:x1])
+		_, nb := keptLines(b[y0:y1])
+		if len(na) != len(nb) {
+			return false
+		}
+		for i := range na {
+			if na[i] != nb[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	b0, l0, r0 := 0, 0, 0
+	for b := 0; b <= len(base); b++ {
+		if b < len(base) && (mapL[b] < 0 || mapR[b] < 0) {
+			continue
+		}
+		l1, r1 := len(left), len(right)
+		if b < len(base) {
+			l1, r1 = mapL[b], mapR[b]
+		}
+		if b0 < b || l0 < l1 || r0 < r1 {
+			c := chunkType{B0: b0, B1: b, L0: l0, L1: l1, R0: r0, R1: r1}
+			lch := !same(base, b0, b, left, l0, l1) && !ignoredHunk(opts, base, left, b0, b, l0, l1)
+			rch := !same(base, b0, b, right, r0, r1) && !ignoredHunk(opts, base, right, b0, b, r0, r1)
+			switch {
+			case lch && rch && same(left, l0, l1, right, r0, r1):
+				c.Kind = chunkBoth
+			case lch && rch:
+				c.Kind = chunkConflict
+			case lch:
+				c.Kind = chunkLeft
+			case rch:
+				c.Kind = chunkRight
+			default:
+				c.Kind = chunkSame
+			}
+			chunks = append(chunks, c)
+		}
+		if b < len(base) {
+			chunks = append(chunks, chunkType{Kind: chunkSame, B0: b, B1: b, L0: l1, L1: l1, R0: r1, R1: r1})
+		}
+		b0, l0, r0 = b+1, l1+1, r1+1
+	}
+	return
+}
+
+// baseMap maps each line in the base to the line in the other file that
+// it matches or -1.
+func baseMap(opts options, base, other []lineType) []int {
+	m := make([]int, len(base))
+	for i := range m {
+		m[i] = -1
+	}
+	keep1, norm1 := keptLines(base)
+	keep2, norm2 := keptLines(other)
+	for _, p := range matchPoints(opts.Algorithm, norm1, norm2) {
+		m[keep1[p[0]]] = keep2[p[1]]
+	}
+	return m
+}
+
+// stable reports whether the chunk is a stable line.
+func (c chunkType) stable() bool {
+	return c.B0 == c.B1 && c.L0 == c.L1 && c.R0 == c.R1
+}
+
+// update updates the summary data for a chunk.
+func (sum *diff3SummaryType) update(c chunkType) {
+	switch {
+	case c.stable():
+		sum.NumStableLines++
+	case c.Kind == chunkLeft:
+		sum.NumLeftChanges++
+	case c.Kind == chunkRight:
+		sum.NumRightChanges++
+	case c.Kind == chunkBoth:
+		sum.NumBothChanges++
+	case c.Kind == chunkConflict:
+		sum.NumConflicts++
+	}
+}
+
+// chunkOpts returns the options used to print the base, left and right
+// lines of a chunk. The color for matching lines is replaced by the
+// color for the kind of change in each file that changed.
+func chunkOpts(opts options, kind int) (bopts, lopts, ropts options) {
+	bopts, lopts, ropts = opts, opts, opts
+	switch kind {
+	case chunkLeft:
+		bopts.Colors.LinesMatch = opts.Colors.LeftChange
+		lopts.Colors.LinesMatch = opts.Colors.LeftChange
+	case chunkRight:
+		bopts.Colors.LinesMatch = opts.Colors.RightChange
+		ropts.Colors.LinesMatch = opts.Colors.RightChange
+	case chunkBoth:
+		lopts.Colors.LinesMatch = opts.Colors.LeftChange
+		ropts.Colors.LinesMatch = opts.Colors.RightChange
+	case chunkConflict:
+		bopts.Colors.LinesMatch = opts.Colors.Conflict
+		lopts.Colors.LinesMatch = opts.Colors.Conflict
+		ropts.Colors.LinesMatch = opts.Colors.Conflict
+	}
+	return
+}
+
+// chunkSymbols are the symbols shown between the panes for each kind of
+// chunk.
+var chunkSymbols = []string{" ", "<", ">", "=", "!"}
+
+// sdiff3 prints the three way diff in three panes: base, left and right.
+// The symbol between the panes shows the kind of change: < for a left
+// change, > for a right change, = for the same change in both files and
+// ! for a conflict.
+func (sum *diff3SummaryType) sdiff3(opts options, base, left, right []lineType, chunks []chunkType) {
+	width := (opts.Width - 6) / 3
+	blank := fmt.Sprintf("%6s %-*s", "", width-7, "")
+
+	// Print the header.
+	fmt.Println("")
+	for i, file := range []string{opts.Base, opts.File1, opts.File2} {
+		fmt.Printf("%6s ", "")
+		if i < 2 {
+			fmt.Print(padRight(trunc(file, width-7), width-7))
+			fmt.Printf("   ")
+		} else {
+			fmt.Printf("%v", trunc(file, width-7))
+		}
+	}
+	fmt.Println("")
+
+	for _, c := range chunks {
+		sum.update(c)
+		if c.stable() {
+			if opts.Suppress == false {
+				printLine(opts, c.B0+1, base[c.B0], width, true, []bool{}, true)
+				printSymbol(opts, "   ")
+				printLine(opts, c.L0+1, left[c.L0], width, true, []bool{}, true)
+				printSymbol(opts, "   ")
+				printLine(opts, c.R0+1, right[c.R0], width, false, []bool{}, true)
+				fmt.Println("")
+			}
+			continue
+		}
+		bopts, lopts, ropts := chunkOpts(opts, c.Kind)
+		sym := " " + chunkSymbols[c.Kind] + " "
+		for k := 0; c.B0+k < c.B1 || c.L0+k < c.L1 || c.R0+k < c.R1; k++ {
+			if b := c.B0 + k; b < c.B1 {
+				printLine(bopts, b+1, base[b], width, true, []bool{}, true)
+			} else {
+				fmt.Print(blank)
+			}
+			printSymbol(opts, sym)
+			if l := c.L0 + k; l < c.L1 {
+				printLine(lopts, l+1, left[l], width, true, []bool{}, true)
+			} else {
+				fmt.Print(blank)
+			}
+			printSymbol(opts, sym)
+			if r := c.R0 + k; r < c.R1 {
+				printLine(ropts, r+1, right[r], width, false, []bool{}, true)
+			}
+			fmt.Println("")
+		}
+	}
+	fmt.Println("")
+}
+
+// diff3 prints the three way diff as a listing like the diff3 tool. The
+// files are numbered like diff3 LEFT BASE RIGHT: 1 is the left file, 2
+// is the base and 3 is the right file. Each chunk starts with ==== for a
+// conflict or ====N where N is the file that is different from the other
+// two. The unchanged chunks are not shown.
+func (sum *diff3SummaryType) diff3(opts options, base, left, right []lineType, chunks []chunkType) {
+	for _, c := range chunks {
+		sum.update(c)
+		if c.stable() || c.Kind == chunkSame {
+			continue
+		}
+		bopts, lopts, ropts := chunkOpts(opts, c.Kind)
+		header := map[int]string{chunkLeft: "====1", chunkRight: "====3", chunkBoth: "====2", chunkConflict: "===="}
+		printSymbol(opts, header[c.Kind])
+		fmt.Println("")
+
+		// lambda to print the lines of a file in the chunk.
+		printFile := func(n int, fopts options, lines []lineType, x0, x1 int, text bool) {
+			if x0 == x1 {
+				fmt.Printf("%v:%va\n", n, x0)
+			} else if x1-x0 == 1 {
+				fmt.Printf("%v:%vc\n", n, x1)
+			} else {
+				fmt.Printf("%v:%v,%vc\n", n, x0+1, x1)
+			}
+			for x := x0; text && x < x1; x++ {
+				fmt.Print("  ")
+				printLine(fopts, -1, lines[x], -1, false, []bool{}, true)
+				fmt.Println("")
+			}
+		}
+		// The text of the two files that are the same is only shown
+		// once, after the second one.
+		switch c.Kind {
+		case chunkLeft:
+			printFile(1, lopts, left, c.L0, c.L1, true)
+			printFile(2, bopts, base, c.B0, c.B1, false)
+			printFile(3, ropts, right, c.R0, c.R1, true)
+		case chunkRight:
+			printFile(1, lopts, left, c.L0, c.L1, false)
+			printFile(2, bopts, base, c.B0, c.B1, true)
+			printFile(3, ropts, right, c.R0, c.R1, true)
+		case chunkBoth:
+			printFile(1, lopts, left, c.L0, c.L1, false)
+			printFile(3, ropts, right, c.R0, c.R1, true)
+			printFile(2, bopts, base, c.B0, c.B1, true)
+		default:
+			printFile(1, lopts, left, c.L0, c.L1, true)
+			printFile(2, bopts, base, c.B0, c.B1, true)
+			printFile(3, ropts, right, c.R0, c.R1, true)
+		}
+	}
+}
+
+// printSummary3 prints the diff3 summary.
+func printSummary3(sum diff3SummaryType) {
+	fct := func(key string, val int) {
+		fmt.Printf("%-30s : %6d\n", key, val)
+	}
+
+	fct("summary: NumStableLines", sum.NumStableLines)
+	fct("summary: NumLeftChanges", sum.NumLeftChanges)
+	fct("summary: NumRightChanges", sum.NumRightChanges)
+	fct("summary: NumBothChanges", sum.NumBothChanges)
+	fct("summary: NumConflicts", sum.NumConflicts)
+}
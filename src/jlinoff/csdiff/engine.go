@@ -3,11 +3,7 @@
 // Copyright (c) 2017 Joe Linoff
 package main
 
-import (
-	"fmt"
-	"strings"
-	"unicode/utf8"
-)
+import "fmt"
 
 // Summary information.
 type diffSummaryType struct {
@@ -21,84 +17,110 @@ type diffSummaryType struct {
 	NumLeftCharsMatch  int
 	NumRightCharsMatch int
 	NumRightCharsDiff  int
+	NumLinesIgnored    int
 }
 
-// Run the diff using the longest common subsequence, do not print anything.
-func diffInit(opts options) (seq1, seq2 []string, mp [][]int) {
-	seq1 = filter(opts, readLines(opts.File1))
-	seq2 = filter(opts, readLines(opts.File2))
+// Run the diff using the selected algorithm, do not print anything.
+// The lines are compared using their normalized text.
+// The hunks of changed lines between the match points are also found.
+func diffInit(opts options) (seq1, seq2 []lineType, mp [][]int, hunks [][]hunkType) {
+	lines1 := readInput(opts.File1, opts.PrefilterLeft)
+	lines2 := readInput(opts.File2, opts.PrefilterRight)
+	return diffLines(opts, lines1, lines2)
+}
 
-	// To support options like ignore whitespace or ignore case,
-	// the lines must be modified before the LCS operation.
-	lcs := longestCommonSubsequence(seq1, seq2)
+// diffLines runs the diff on the lines that were read from the files.
+func diffLines(opts options, lines1, lines2 []string) (seq1, seq2 []lineType, mp [][]int, hunks [][]hunkType) {
+	seq1 = filter(opts, lines1, opts.ReplacementsLeft)
+	seq2 = filter(opts, lines2, opts.ReplacementsRight)
 
+	// To support options like ignore whitespace or ignore case,
+	// the lines must be modified before the diff operation.
 	// Find the match points.
 	// First entry is the line number in l1 and the second is the line number in l2.
-	mp = [][]int{}
-	for i := 0; i < len(lcs); i++ {
-		r := []int{-1, -1}
-		mp = append(mp, r)
-	}
-
-	// lambda seq initializer (DRY)
-	initSeq := func(seq []string, k int) {
-		for i, j := 0, 0; i < len(seq) && j < len(lcs); i++ {
-			if seq[i] == lcs[j] {
-				mp[j][k] = i
-				j++
-			}
-		}
+	// The ignored lines are left out so they are never matched.
+	keep1, norm1 := keptLines(seq1)
+	keep2, norm2 := keptLines(seq2)
+	mp = matchPoints(opts.Algorithm, norm1, norm2)
+	matched1 := make([]bool, len(seq1))
+	matched2 := make([]bool, len(seq2))
+	for _, p := range mp {
+		p[0] = keep1[p[0]]
+		p[1] = keep2[p[1]]
+		matched1[p[0]] = true
+		matched2[p[1]] = true
 	}
-	initSeq(seq1, 0) // Initialize for the first sequence.
-	initSeq(seq2, 1) // Initialize the second sequence.
+	ignoreContinuations(seq1, matched1)
+	ignoreContinuations(seq2, matched2)
+	hunks = findHunks(opts, seq1, seq2, mp)
 	return
 }
 
-// filter normalizes the output for comparisons using regular expressions.
-func filter(opts options, lines []string) []string {
-	newLines := []string{}
-	if len(opts.Replacements) > 0 {
-		for _, line := range lines {
-			for _, rep := range opts.Replacements {
-				line = rep.Pattern.ReplaceAllString(line, rep.Replacement)
-			}
-			newLines = append(newLines, line)
-		}
-	} else {
-		newLines = lines
-	}
-	return newLines
+// updateChars updates the character summary data for two lines.
+func (sum *diffSummaryType) updateChars(l1, l2 lineType, refa, refb []bool) {
+	ndiff, nmatch := countChars(l1.Text, refa)
+	sum.NumLeftCharsDiff += ndiff
+	sum.NumLeftCharsMatch += nmatch
+	ndiff, nmatch = countChars(l2.Text, refb)
+	sum.NumRightCharsDiff += ndiff
+	sum.NumRightCharsMatch += nmatch
 }
 
 // diff prints out the diffs in separate sections.
 // It is a better choice for longer lines.
 // Always suppress, ignore the -s option.
-func (sum *diffSummaryType) diff(opts options, seq1, seq2 []string, mp [][]int) {
-	// lambda to print the lines in the interval between
-	// match points.
-	printInterval := func(i1 *int, n1 int, i2 *int, n2 int) {
+func (sum *diffSummaryType) diff(opts options, seq1, seq2 []lineType, mp [][]int, hunks [][]hunkType) {
+	// Find the moved blocks.
+	moved1, moved2 := []int{}, []int{}
+	if opts.Moved {
+		moved1, moved2 = findMoves(seq1, seq2, mp)
+	}
+
+	// lambda to print the line number that a moved line was moved to
+	// or from after the line.
+	printMoved := func(color string, dir string, n int) {
+		if opts.Colorize == true {
+			fmt.Print(color)
+		}
+		fmt.Printf("  (moved %v %d)", dir, n+1)
+		if opts.Colorize == true {
+			fmt.Print(opts.Colors.Reset)
+		}
+	}
+
+	// lambda to print a hunk of lines between match points.
+	printHunk := func(i1 *int, n1 int, i2 *int, n2 int, ignored bool) {
 		x1 := *i1
 		x2 := *i2
 		if x1 >= n1 && x2 >= n2 {
 			return
 		}
 
+		// Ignored hunks are not printed.
+		if ignored {
+			for x1 < n1 || x2 < n2 {
+				x1++
+				x2++
+				sum.NumLinesIgnored++
+			}
+			*i1 = n1
+			*i2 = n2
+			return
+		}
+
+		// Pair the lines by similarity.
+		pairs := pairLines(opts, seq1, seq2, x1, n1, x2, n2)
+
 		// Print the header:
 		//  line numbers for the first file followed by line numbers for the
 		//  second file.
 		y1 := x1
 		y2 := x2
-		for x1 < n1 || x2 < n2 {
-			// Advance the minimum.
-			p1 := x1 < n1
-			p2 := x2 < n2
-
-			if p1 {
-				x1++
-			}
-			if p2 {
-				x2++
-			}
+		x1 = n1
+		x2 = n2
+		for _, p := range pairs {
+			p1 := p[0] >= 0
+			p2 := p[1] >= 0
 
 			// Update the summary data.
 			if p1 && p2 {
@@ -123,64 +145,43 @@ func (sum *diffSummaryType) diff(opts options, seq1, seq2 []string, mp [][]int)
 		fmt.Println("")
 
 		// Print the left diffs.
-		x1 = *i1
-		x2 = *i2
-		for x1 < n1 || x2 < n2 {
-			// Advance the minimum.
-			p1 := x1 < n1
-			p2 := x2 < n2
+		refbs := map[int][]bool{} // right maps by pair index
+		for k, p := range pairs {
+			p1 := p[0] >= 0
+			p2 := p[1] >= 0
 			refa := []bool{}
 
 			if p1 && p2 {
-				refa, _ = mapCommonSubStrings(seq1[x1], seq2[x2])
+				var refb []bool
+				refa, refb = mapLineDiffs(opts, seq1[p[0]], seq2[p[1]])
+				refbs[k] = refb
 				if opts.Summary {
-					for _, match := range refa {
-						if match == false {
-							sum.NumLeftCharsDiff++
-						} else {
-							sum.NumLeftCharsMatch++
-						}
-					}
-
-					for _, match := range refa {
-						if match == false {
-							sum.NumRightCharsDiff++
-						} else {
-							sum.NumRightCharsMatch++
-						}
-					}
+					sum.updateChars(seq1[p[0]], seq2[p[1]], refa, refb)
 				}
 			}
 
 			// Print the left.
 			if p1 {
 				printSymbol(opts, "< ")
-				printLine(opts, -1, seq1[x1], -1, false, refa, p2)
+				if !p2 && len(moved1) > 0 && moved1[p[0]] >= 0 {
+					printLine(movedOpts(opts), -1, seq1[p[0]], -1, true, refa, p2)
+					printMoved(opts.Colors.MovedFrom, "to", moved1[p[0]])
+				} else {
+					printLine(opts, -1, seq1[p[0]], -1, true, refa, p2)
+				}
 				fmt.Println("") // new line
-				x1++
-			}
-
-			if p2 {
-				x2++
 			}
 		}
 
 		// Print the right diffs.
 		first := true
-		x1 = *i1
-		x2 = *i2
-		for x1 < n1 || x2 < n2 {
-			// Advance the minimum.
-			p1 := x1 < n1
-			p2 := x2 < n2
+		for k, p := range pairs {
+			p1 := p[0] >= 0
+			p2 := p[1] >= 0
 			refb := []bool{}
 
 			if p1 && p2 {
-				_, refb = mapCommonSubStrings(seq1[x1], seq2[x2])
-			}
-
-			if p1 {
-				x1++
+				refb = refbs[k]
 			}
 
 			// Print the right.
@@ -190,9 +191,13 @@ func (sum *diffSummaryType) diff(opts options, seq1, seq2 []string, mp [][]int)
 					fmt.Println("---")
 				}
 				printSymbol(opts, "> ")
-				printLine(opts, -1, seq2[x2], -1, false, refb, p1)
+				if !p1 && len(moved2) > 0 && moved2[p[1]] >= 0 {
+					printLine(movedOpts(opts), -1, seq2[p[1]], -1, false, refb, p1)
+					printMoved(opts.Colors.MovedTo, "from", moved2[p[1]])
+				} else {
+					printLine(opts, -1, seq2[p[1]], -1, false, refb, p1)
+				}
 				fmt.Println("") // new line
-				x2++
 			}
 		}
 
@@ -200,28 +205,33 @@ func (sum *diffSummaryType) diff(opts options, seq1, seq2 []string, mp [][]int)
 		*i2 = x2
 	}
 
+	// lambda to print the hunks in the interval between match points.
+	printInterval := func(i1 *int, i2 *int, hunks []hunkType) {
+		for _, h := range hunks {
+			printHunk(i1, h.N1, i2, h.N2, h.Ignored)
+		}
+	}
+
 	// Print all of the diffs.
-	sum.NumLeftLines = len(seq1)
-	sum.NumRightLines = len(seq2)
+	sum.NumLeftLines += len(seq1)
+	sum.NumRightLines += len(seq2)
 	info("num a=matchpoints: %v", len(mp))
 	i1 := 0
 	i2 := 0
 	for i := 0; i < len(mp); i++ {
-		n1 := mp[i][0]
-		n2 := mp[i][1]
-		printInterval(&i1, n1, &i2, n2)
+		printInterval(&i1, &i2, hunks[i])
 		sum.NumLinesMatch++
 		i1++
 		i2++
 	}
-	printInterval(&i1, len(seq1), &i2, len(seq2))
+	printInterval(&i1, &i2, hunks[len(mp)])
 	fmt.Println("")
 }
 
 // sdiff prints out the side by side diff.
 // It uses the long common substring recursively to get the smallest set of
 // differences.
-func (sum *diffSummaryType) sdiff(opts options, seq1, seq2 []string, mp [][]int) {
+func (sum *diffSummaryType) sdiff(opts options, seq1, seq2 []lineType, mp [][]int, hunks [][]hunkType) {
 	// Adjust the width for each side.
 	// Define the formats.
 	width := (opts.Width - 2) / 2
@@ -230,53 +240,87 @@ func (sum *diffSummaryType) sdiff(opts options, seq1, seq2 []string, mp [][]int)
 	}
 
 	fmt1 := fmt.Sprintf("%%6s %%-%ds", width-7) // left line + line num
-	fmt2 := fmt.Sprintf("%%-%ds", width-7)      // left line only
 
 	// Print the header.
 	fmt.Println("")
 	fmt.Printf("%6s ", "")
-	fmt.Printf(fmt2, trunc(opts.File1, width-7))
+	fmt.Print(padRight(trunc(opts.File1, width-7), width-7))
 	fmt.Printf("   ")
 	fmt.Printf("%6s ", "")
 	fmt.Printf("%v", trunc(opts.File2, width-7))
 	fmt.Println("")
 
-	// lambda to print the lines in the interval between
-	// match points.
-	printInterval := func(i1 *int, n1 int, i2 *int, n2 int) {
-		for *i1 < n1 || *i2 < n2 {
-			// Advance the minimum.
-			p1 := *i1 < n1
-			p2 := *i2 < n2
+	// Find the moved blocks.
+	// The line in the other file is shown in the empty line number
+	// column as @N.
+	moved1, moved2 := []int{}, []int{}
+	if opts.Moved {
+		moved1, moved2 = findMoves(seq1, seq2, mp)
+	}
+	printMoved := func(color string, n int) {
+		if opts.Colorize == true {
+			fmt.Print(color)
+		}
+		fmt.Printf("%6s", fmt.Sprintf("@%d", n+1))
+		if opts.Colorize == true {
+			fmt.Print(opts.Colors.Reset)
+		}
+	}
+
+	// lambda to print a hunk of lines between match points.
+	printHunk := func(i1 *int, n1 int, i2 *int, n2 int, ignored bool) {
+		// Ignored hunks are printed as context unless the common
+		// lines are suppressed.
+		if ignored {
+			iopts := ignoredOpts(opts)
+			for *i1 < n1 || *i2 < n2 {
+				if opts.Suppress == false {
+					if *i1 < n1 {
+						printLine(iopts, *i1+1, seq1[*i1], width, true, []bool{}, true)
+					} else {
+						fmt.Printf(fmt1, "", "")
+					}
+					printSymbol(opts, "   ")
+					if *i2 < n2 {
+						printLine(iopts, *i2+1, seq2[*i2], width, false, []bool{}, true)
+					}
+					fmt.Println("")
+				}
+				if *i1 < n1 {
+					*i1++
+				}
+				if *i2 < n2 {
+					*i2++
+				}
+				sum.NumLinesIgnored++
+			}
+			return
+		}
+
+		// Pair the lines by similarity.
+		for _, p := range pairLines(opts, seq1, seq2, *i1, n1, *i2, n2) {
+			p1 := p[0] >= 0
+			p2 := p[1] >= 0
 			refa := []bool{}
 			refb := []bool{}
 
 			if p1 && p2 {
-				refa, refb = mapCommonSubStrings(seq1[*i1], seq2[*i2])
+				refa, refb = mapLineDiffs(opts, seq1[p[0]], seq2[p[1]])
 
 				// update the summary data
 				if opts.Summary {
-					for _, match := range refa {
-						if match == false {
-							sum.NumLeftCharsDiff++
-						} else {
-							sum.NumLeftCharsMatch++
-						}
-					}
-					for _, match := range refb {
-						if match == false {
-							sum.NumRightCharsDiff++
-						} else {
-							sum.NumRightCharsMatch++
-						}
-					}
+					sum.updateChars(seq1[p[0]], seq2[p[1]], refa, refb)
 				}
 			}
 
 			// Print the left.
-			if p1 {
-				printLine(opts, *i1+1, seq1[*i1], width, true, refa, p2)
-				*i1++
+			if p1 && !p2 && len(moved1) > 0 && moved1[p[0]] >= 0 {
+				printLine(movedOpts(opts), p[0]+1, seq1[p[0]], width, true, refa, p2)
+			} else if p1 {
+				printLine(opts, p[0]+1, seq1[p[0]], width, true, refa, p2)
+			} else if len(moved2) > 0 && moved2[p[1]] >= 0 {
+				printMoved(opts.Colors.MovedTo, moved2[p[1]])
+				fmt.Printf(" %-*s", width-7, "")
 			} else {
 				fmt.Printf(fmt1, "", "")
 			}
@@ -293,9 +337,12 @@ func (sum *diffSummaryType) sdiff(opts options, seq1, seq2 []string, mp [][]int)
 			}
 
 			// Print the right.
-			if p2 {
-				printLine(opts, *i2+1, seq2[*i2], width, false, refb, p1)
-				*i2++
+			if p2 && !p1 && len(moved2) > 0 && moved2[p[1]] >= 0 {
+				printLine(movedOpts(opts), p[1]+1, seq2[p[1]], width, false, refb, p1)
+			} else if p2 {
+				printLine(opts, p[1]+1, seq2[p[1]], width, false, refb, p1)
+			} else if len(moved1) > 0 && moved1[p[0]] >= 0 {
+				printMoved(opts.Colors.MovedFrom, moved1[p[0]])
 			} else {
 				fmt.Printf("")
 			}
@@ -311,19 +358,31 @@ func (sum *diffSummaryType) sdiff(opts options, seq1, seq2 []string, mp [][]int)
 				sum.NumRightOnlyLines++
 			}
 		}
+		*i1 = n1
+		*i2 = n2
+	}
+
+	// lambda to print the hunks in the interval between match points.
+	printInterval := func(i1 *int, i2 *int, hunks []hunkType) {
+		for _, h := range hunks {
+			printHunk(i1, h.N1, i2, h.N2, h.Ignored)
+		}
 	}
 
 	// Print the diffs and the matching lines.
+	sum.NumLeftLines += len(seq1)
+	sum.NumRightLines += len(seq2)
 	i1 := 0
 	i2 := 0
 	for i := 0; i < len(mp); i++ {
 		n1 := mp[i][0]
 		n2 := mp[i][1]
-		printInterval(&i1, n1, &i2, n2)
+		printInterval(&i1, &i2, hunks[i])
 		sum.NumLinesMatch++
 		if opts.Suppress == false {
 			// If suppression is off, print the matches.
-			// Since they match we can use seq1 for everything.
+			// The normalized text matches but the original text
+			// may not so each side is printed.
 			printLine(opts, n1+1, seq1[n1], width, true, []bool{}, true)
 			if opts.Colorize == true {
 				fmt.Print(opts.Colors.Symbol)
@@ -338,7 +397,7 @@ func (sum *diffSummaryType) sdiff(opts options, seq1, seq2 []string, mp [][]int)
 		i1++
 		i2++
 	}
-	printInterval(&i1, len(seq1), &i2, len(seq2))
+	printInterval(&i1, &i2, hunks[len(mp)])
 	fmt.Println("")
 }
 
@@ -357,198 +416,178 @@ func printSymbol(opts options, sym string) {
 // left - true if left, false if right
 // refs - map of character diffs
 // both - both lines have values
-func printLine(opts options, lineNum int, line string, width int, left bool, ref []bool, both bool) {
+func printLine(opts options, lineNum int, line lineType, width int, left bool, ref []bool, both bool) {
 	if lineNum > 0 {
 		fmt.Printf("%6d ", lineNum)
 	}
 	w := width - 7
-	s := trunc(line, w)
-	nr := 0 // num runes
+	s := trunc(line.Text, w)
+	nw := 0 // display width
 
 	// doColor was added so that this could be used by matching lines without
 	// overhead.
 	if opts.Colorize {
 		// The user specified the -c option, use the color map.
-		if len(ref) > 0 {
-			// Two lines, each have diffs.
+		// The masked text is only highlighted if it has a color.
+		mask := []bool{}
+		if len(opts.Colors.Masked) > 0 {
+			mask = line.masked()
+		}
+
+		// The color used when there are no character diffs.
+		lineColor := opts.Colors.LinesMatch // both lines match
+		if both == false {
+			if left == true {
+				// only the left line
+				lineColor = opts.Colors.LeftLineOnly
+			} else { // left is false
+				// only the right line
+				lineColor = opts.Colors.RightLineOnly
+			}
+		}
+
+		if len(ref) > 0 || len(mask) > 0 {
+			// Two lines, each have diffs or there is masked text.
+			// lambda to get the color for a character.
+			charColor := func(i int) string {
+				if len(mask) > 0 && mask[i] {
+					return opts.Colors.Masked
+				} else if len(ref) == 0 {
+					return lineColor
+				} else if ref[i] == false {
+					return opts.Colors.CharsDiff
+				}
+				return opts.Colors.CharsMatch
+			}
+			// The maps are indexed by byte offset.
 			fmt.Print(opts.Colors.Reset)
 			i := 0
-			for i < len(s) && (nr < w || w < 1) {
-				if (nr > 0 && ref[nr] != ref[nr-1]) || (nr == 0) {
+			color := ""
+			for _, c := range graphemes(s) {
+				if cc := charColor(i); cc != color || i == 0 {
 					// Check the difference map (r) to see if we
 					// need to colorize.
-					if ref[nr] == false {
-						fmt.Print(opts.Colors.Reset)
-						fmt.Print(opts.Colors.CharsDiff)
-					} else {
-						fmt.Print(opts.Colors.Reset)
-						fmt.Print(opts.Colors.CharsMatch)
-					}
+					fmt.Print(opts.Colors.Reset)
+					fmt.Print(cc)
+					color = cc
 				}
-				rv, width := utf8.DecodeRuneInString(s[i:])
-				fmt.Printf("%c", rv)
-				i += width
-				nr++
+				fmt.Print(c)
+				i += len(c)
+				nw += clusterWidth(c)
 			}
 		} else {
-			if both == true {
-				// both lines match
-				fmt.Print(opts.Colors.LinesMatch)
-			} else if left == true {
-				// only the left line
-				fmt.Print(opts.Colors.LeftLineOnly)
-			} else { // left is false
-				// only the right line
-				fmt.Print(opts.Colors.RightLineOnly)
-			}
-			nr = len(s)
+			fmt.Print(lineColor)
+			nw = stringWidth(s)
 			fmt.Printf("%v", s)
 		}
 		fmt.Print(opts.Colors.Reset)
 	} else {
-		nr = len(s)
+		nw = stringWidth(s)
 		fmt.Printf("%v", s)
 	}
 
 	// Pad if this is the left side.
 	if left {
-		for nr < w {
+		for nw < w {
 			fmt.Printf(" ")
-			nr++
+			nw++
 		}
 	}
 }
 
 // mapCommonSubStrings - maps common sub strings.
 // The entry is true if they match or false otherwise.
-func mapCommonSubStrings(a, b string) (refa, refb []bool) {
+// The strings are split into tokens which are normally grapheme clusters
+// so that multi-byte characters are never split. The maps are indexed by
+// byte offset.
+func mapCommonSubStrings(a, b string, tokenize func(string) []string) (refa, refb []bool) {
+	ta := tokenize(a)
+	tb := tokenize(b)
+	ma, mb := mapCommonTokens(ta, tb)
+	refa = expandTokenMap(ta, ma)
+	refb = expandTokenMap(tb, mb)
+	return
+}
+
+// mapCommonTokens - maps common token sequences using the longest
+// common substring recursively.
+// The entry is true if they match or false otherwise.
+func mapCommonTokens(a, b []string) (refa, refb []bool) {
 	refa = make([]bool, len(a))
 	refb = make([]bool, len(b))
 
-	type fctType func(fctType, string, string, int, int, int)
-	fct := func(f fctType, as, bs string, offa, offb int, depth int) {
-		if len(a) < 1 || len(b) < 1 {
+	type fctType func(fctType, int, int, int, int)
+	fct := func(f fctType, aLo, aHi, bLo, bHi int) {
+		if aLo >= aHi || bLo >= bHi {
 			return
 		}
 
-		x := longestCommonSubstring(as, bs)
-		if len(x) < 1 {
+		ia, ib, n := longestCommonSubstring(a[aLo:aHi], b[bLo:bHi])
+		if n < 1 {
 			return
 		}
-
-		pa := strings.Index(as, x)
-		pb := strings.Index(bs, x)
-		ia := pa
-		ib := pb
-		for i := 0; i < len(x); i++ {
-			refa[ia+offa] = true
-			refb[ib+offb] = true
-			ia++
-			ib++
+		for i := 0; i < n; i++ {
+			refa[aLo+ia+i] = true
+			refb[bLo+ib+i] = true
 		}
 
-		// Left recursive all does not require index updates.
-		f(f, as[:pa], bs[:pb], offa, offb, depth+1)
-		// Right recursion requires updating the offsets.
-		f(f, as[ia:], bs[ib:], offa+ia, offb+ib, depth+1)
+		// Recurse on the left and the right of the common substring.
+		f(f, aLo, aLo+ia, bLo, bLo+ib)
+		f(f, aLo+ia+n, aHi, bLo+ib+n, bHi)
 	}
 
-	fct(fct, a, b, 0, 0, 0)
+	fct(fct, 0, len(a), 0, len(b))
 	return
 }
 
-// longestCommonSubsequence
-// Find the longest common subsequence between two strings.
-func longestCommonSubsequence(seq1 []string, seq2 []string) []string {
-	seq1Len := len(seq1)
-	seq2Len := len(seq2)
-	matrix := [][]int{}
-
-	// initialize to -1
-	// make sure it is 1 larger than necessary to
-	// handle the case where the last entry in each row/column
-	// mismatches
-	for i := 0; i <= seq1Len; i++ {
-		row := []int{}
-		for j := 0; j <= seq2Len; j++ {
-			row = append(row, -1)
-		}
-		matrix = append(matrix, row)
-	}
-
-	// Create the matrix of the longest subsequence lengths.
-	// lambda to get the max of two integers.
-	maxi := func(a, b int) int {
-		if a >= b {
-			return a
-		}
-		return b
-	}
-	for i := 0; i < seq1Len; i++ {
-		for j := 0; j < seq2Len; j++ {
-			if i == 0 || j == 0 {
-				matrix[i][j] = 0
-			} else if seq1[i-1] == seq2[j-1] {
-				matrix[i][j] = 1 + matrix[i-1][j-1]
-			} else {
-				matrix[i][j] = maxi(matrix[i-1][j], matrix[i][j-1])
-			}
+// expandTokenMap expands a token map to a byte map.
+func expandTokenMap(tokens []string, ref []bool) []bool {
+	out := []bool{}
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			out = append(out, ref[i])
 		}
 	}
+	return out
+}
 
-	// Backtrack from the lower, right corner to get the characters.
-	i := seq1Len
-	j := seq2Len
-	rlcs := []string{} // reversed version of the LCS
-	for i > 0 && j > 0 {
-		if seq1[i-1] == seq2[j-1] {
-			// If the current char in seq1 and seq2, then
-			// it is part of the LCS.
-			rlcs = append(rlcs, seq1[i-1])
-			i--
-			j--
-		} else if matrix[i-1][j] > matrix[i][j-1] {
-			// They aren't the same, look at the previous entry in the row,
-			// if it is the larger value, choose it.
-			i--
+// countChars counts the characters (grapheme clusters) that differ and
+// match in a line using its byte map.
+func countChars(text string, ref []bool) (ndiff, nmatch int) {
+	i := 0
+	for _, c := range graphemes(text) {
+		if i < len(ref) && ref[i] {
+			nmatch++
 		} else {
-			j--
-		}
-	}
-
-	// lambda function to reverse a list of strings.
-	// simular rlcs[::-1]
-	reverse := func(list []string) (result []string) {
-		for i := len(list) - 1; i >= 0; i-- {
-			result = append(result, list[i])
+			ndiff++
 		}
-		return
+		i += len(c)
 	}
-
-	lcs := reverse(rlcs)
-	return lcs
+	return
 }
 
 // longestCommonSubstring find the longest common substring between two
-// two strings.
+// token sequences. It returns the start of the substring in each
+// sequence and its length.
+// Only two rows of the lengths matrix are kept.
 // CITATION: https://rosettacode.org/wiki/Longest_Common_Substring#Go
-func longestCommonSubstring(a, b string) (out string) {
-	lengths := make([]int, len(a)*len(b))
-	greatestLength := 0
-	for i, x := range a {
-		for j, y := range b {
-			if x == y {
-				if i == 0 || j == 0 {
-					lengths[i*len(b)+j] = 1
-				} else {
-					lengths[i*len(b)+j] = lengths[(i-1)*len(b)+j-1] + 1
-				}
-				if lengths[i*len(b)+j] > greatestLength {
-					greatestLength = lengths[i*len(b)+j]
-					out = a[i-greatestLength+1 : i+1]
+func longestCommonSubstring(a, b []string) (ia, ib, n int) {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				curr[j] = prev[j-1] + 1
+				if curr[j] > n {
+					n = curr[j]
+					ia = i - n
+					ib = j - n
 				}
+			} else {
+				curr[j] = 0
 			}
 		}
+		prev, curr = curr, prev
 	}
 	return
 }
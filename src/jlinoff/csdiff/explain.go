@@ -0,0 +1,149 @@
+// Report the impact of the replacement rules.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// explainType is the impact of a replacement rule.
+// Left and Right are the number of substitutions in each file, Hidden
+// is the number of changed lines that are no longer differences because
+// of the rule and Added is the number of lines that are differences only
+// because of the rule.
+type explainType struct {
+	Option string
+	Rule   replaceType
+	Left   int
+	Right  int
+	Hidden int
+	Added  int
+}
+
+// explainRules reports the impact of each replacement rule.
+//
+// The substitutions are counted in the order that the rules are applied
+// so a rule does not count the text that was already replaced by an
+// earlier rule. The hidden and added differences are found by running
+// the diff again without the rule and comparing the changed lines. The
+// lines are the ones that were already read so the prefilter commands
+// are not run again.
+func explainRules(opts options, seq1, seq2 []lineType, hunks [][]hunkType) {
+	// lambda to get the lines that were read.
+	texts := func(seq []lineType) (lines []string) {
+		for _, l := range seq {
+			lines = append(lines, l.Text)
+		}
+		return
+	}
+	lines1 := texts(seq1)
+	lines2 := texts(seq2)
+	changed := changedSet(hunks)
+
+	// lambda to explain each rule in a list of rules. The rules that
+	// are applied before the list on each side are passed in, a side is
+	// skipped if the rules do not apply to it.
+	explains := []explainType{}
+	explain := func(option string, list *[]replaceType, left bool, before1 []replaceType, right bool, before2 []replaceType, text bool) {
+		rules := *list
+		for k, rule := range rules {
+			e := explainType{Option: option, Rule: rule}
+			if left {
+				prefix := append(append([]replaceType{}, before1...), rules[:k+1]...)
+				e.Left = countSubstitutions(lines1, opts.ReplacementsText, prefix, text)
+			}
+			if right {
+				prefix := append(append([]replaceType{}, before2...), rules[:k+1]...)
+				e.Right = countSubstitutions(lines2, opts.ReplacementsText, prefix, text)
+			}
+
+			// Remove the rule and run the diff again.
+			*list = append(append([]replaceType{}, rules[:k]...), rules[k+1:]...)
+			_, _, _, without := diffLines(opts, lines1, lines2)
+			*list = rules
+			others := changedSet(without)
+			for k := range others {
+				if !changed[k] {
+					e.Hidden++
+				}
+			}
+			for k := range changed {
+				if !others[k] {
+					e.Added++
+				}
+			}
+			explains = append(explains, e)
+		}
+	}
+	explain("--replace-multiline", &opts.ReplacementsText, true, nil, true, nil, true)
+	explain("--replace-left", &opts.ReplacementsLeft, true, nil, false, nil, false)
+	explain("--replace-right", &opts.ReplacementsRight, false, nil, true, nil, false)
+	explain("-r", &opts.Replacements, true, opts.ReplacementsLeft, true, opts.ReplacementsRight, false)
+
+	fmt.Printf("%-8s %6s %6s %6s %6s  %v\n", "explain:", "Left", "Right", "Hidden", "Added", "Rule")
+	for _, e := range explains {
+		fmt.Printf("%-8s %6d %6d %6d %6d  %v '%v' '%v'\n", "explain:", e.Left, e.Right, e.Hidden, e.Added, e.Option, e.Rule.Pattern, e.Rule.Replacement)
+	}
+}
+
+// countSubstitutions counts the substitutions made by the last rule in
+// the lines of a file. The other rules are the ones that filter applies
+// before it. If text is true the rules are multiline rules that are
+// matched against the whole text, otherwise they are matched against the
+// lines after the multiline rules are applied.
+func countSubstitutions(lines []string, multiline []replaceType, rules []replaceType, text bool) int {
+	// lambda to apply the rules and count the matches of the last one.
+	count := func(s string) int {
+		for _, rule := range rules[:len(rules)-1] {
+			s = rule.Pattern.ReplaceAllString(s, rule.Replacement)
+		}
+		return len(rules[len(rules)-1].Pattern.FindAllStringIndex(s, -1))
+	}
+	if text {
+		return count(strings.Join(lines, "\n"))
+	}
+
+	// The continuation lines are not compared so they are skipped.
+	n := 0
+	for _, line := range replaceText(multiline, lines) {
+		if !line.Continuation {
+			n += count(line.Norm)
+		}
+	}
+	return n
+}
+
+// changedSet returns the changed lines in the hunks that are not
+// ignored. The keys are the line numbers, negative for the left file.
+func changedSet(hunks [][]hunkType) map[int]bool {
+	set := map[int]bool{}
+	for _, interval := range hunks {
+		for _, h := range interval {
+			if h.Ignored {
+				continue
+			}
+			for x := h.X1; x < h.N1; x++ {
+				set[-x-1] = true
+			}
+			for x := h.X2; x < h.N2; x++ {
+				set[x] = true
+			}
+		}
+	}
+	return set
+}
+
+// changedLines returns the number of changed lines in the hunks that are
+// not ignored.
+func changedLines(hunks [][]hunkType) (n int) {
+	for _, interval := range hunks {
+		for _, h := range interval {
+			if !h.Ignored {
+				n += h.N1 - h.X1 + h.N2 - h.X2
+			}
+		}
+	}
+	return
+}
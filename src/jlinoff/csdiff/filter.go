@@ -0,0 +1,238 @@
+// Line normalization.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import "regexp"
+
+// lineType is a line from a file along with the normalized version of it
+// that is used for the comparisons. The original text is always what is
+// displayed.
+//
+// Origin maps each byte of Norm back to Text. A value >= 0 is the offset
+// of the byte in Text that it came from, a negative value -(g+1) means
+// that the byte was produced by replacement group g. Mask maps each byte
+// of Text to the replacement group that masked it or -1 if it was not
+// masked. Origin is nil if the line was not changed by the filters and
+// Mask is nil if it was not changed by a replacement.
+//
+// Ignored is true if the line matched one of the ignore lines patterns.
+// Ignored lines are not compared.
+type lineType struct {
+	Text    string
+	Norm    string
+	Origin  []int
+	Mask    []int
+	Ignored bool
+
+	// Continuation is true for the lines after the first line of a
+	// multiline replacement match. They are not compared, they are
+	// changed or ignored with the first line.
+	Continuation bool
+}
+
+// filter normalizes the lines for comparisons using regular expressions.
+// The multiline replacements are applied to the whole text first. Then the
+// replacements for the side are applied followed by the user replacements
+// and the white space normalizations. The ignore lines patterns are
+// matched against the original text.
+func filter(opts options, lines []string, side []replaceType) []lineType {
+	reps := append([]replaceType{}, side...)
+	reps = append(reps, opts.Replacements...)
+	reps = append(reps, whitespaceRules(opts)...)
+	newLines := replaceText(opts.ReplacementsText, lines)
+	for i, line := range lines {
+		if newLines[i].Continuation {
+			continue // continuation of a multiline replacement
+		}
+		if len(reps) > 0 {
+			newLines[i].replace(reps)
+		}
+		for _, re := range opts.IgnoreLines {
+			if re.MatchString(line) {
+				newLines[i].Ignored = true
+				break
+			}
+		}
+		newLines[i].normalizeCase(opts)
+	}
+	return newLines
+}
+
+// whitespaceRules returns the replacement rules that normalize the
+// white space for the comparisons.
+func whitespaceRules(opts options) []replaceType {
+	trailing := replaceType{Pattern: regexp.MustCompile(`\s+$`), Replacement: ""}
+	spaces := regexp.MustCompile(`\s+`)
+	switch {
+	case opts.IgnoreAllSpace:
+		return []replaceType{{Pattern: spaces, Replacement: ""}}
+	case opts.IgnoreSpaceChange:
+		return []replaceType{trailing, {Pattern: spaces, Replacement: " "}}
+	case opts.IgnoreTrailingSpace:
+		return []replaceType{trailing}
+	}
+	return []replaceType{}
+}
+
+// replace applies the replacements to the normalized text and keeps track
+// of where each byte came from so that the differences found in the
+// normalized text can be mapped back to the original text.
+func (l *lineType) replace(reps []replaceType) {
+	norm := l.Norm
+	origin := l.Origin
+	if origin == nil {
+		origin = make([]int, len(norm))
+		for i := range origin {
+			origin[i] = i
+		}
+	}
+	mask := make([]int, len(l.Text))
+	for i := range mask {
+		mask[i] = -1
+	}
+	copy(mask, l.Mask)
+
+	// Each match creates a new group. If a match covers text produced by
+	// an earlier match, that group is merged into the new one. The groups
+	// from the earlier filters are kept.
+	parent := []int{}
+	for _, g := range mask {
+		for len(parent) <= g {
+			parent = append(parent, len(parent))
+		}
+	}
+	for _, o := range origin {
+		for len(parent) < -o {
+			parent = append(parent, len(parent))
+		}
+	}
+	find := func(g int) int {
+		for parent[g] != g {
+			g = parent[g]
+		}
+		return g
+	}
+
+	changed := false
+	for _, rep := range reps {
+		matches := rep.Pattern.FindAllStringSubmatchIndex(norm, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		changed = true
+		newNorm := []byte{}
+		newOrigin := []int{}
+		last := 0
+		for _, m := range matches {
+			g := len(parent)
+			parent = append(parent, g)
+			newNorm = append(newNorm, norm[last:m[0]]...)
+			newOrigin = append(newOrigin, origin[last:m[0]]...)
+			for _, o := range origin[m[0]:m[1]] {
+				if o >= 0 {
+					mask[o] = g
+				} else {
+					parent[find(-o-1)] = g
+				}
+			}
+			n := len(newNorm)
+			newNorm = rep.Pattern.ExpandString(newNorm, rep.Replacement, norm, m)
+			for i := n; i < len(newNorm); i++ {
+				newOrigin = append(newOrigin, -(g + 1))
+			}
+			last = m[1]
+		}
+		newNorm = append(newNorm, norm[last:]...)
+		newOrigin = append(newOrigin, origin[last:]...)
+		norm = string(newNorm)
+		origin = newOrigin
+	}
+	if !changed {
+		return
+	}
+
+	// Resolve the merged groups.
+	for i, o := range origin {
+		if o < 0 {
+			origin[i] = -(find(-o-1) + 1)
+		}
+	}
+	for i, g := range mask {
+		if g >= 0 {
+			mask[i] = find(g)
+		}
+	}
+	l.Norm = norm
+	l.Origin = origin
+	l.Mask = mask
+}
+
+// masked returns the map of the bytes in Text that were masked by a
+// replacement. It is empty if nothing was masked.
+func (l lineType) masked() []bool {
+	ref := []bool{}
+	if l.Mask != nil {
+		ref = make([]bool, len(l.Mask))
+		for i, g := range l.Mask {
+			ref[i] = g >= 0
+		}
+	}
+	return ref
+}
+
+// project maps a character difference map of Norm onto Text.
+// A masked region matches if all of its replacement text matches. If
+// several bytes of Norm came from the same byte of Text, it matches if
+// all of them match. The bytes of Text that are not the origin of any
+// byte of Norm, like the rest of a case folded character or a composed
+// combining mark, use the map of the byte before them.
+func (l lineType) project(ref []bool) []bool {
+	if l.Origin == nil {
+		return ref
+	}
+	out := make([]bool, len(l.Text))
+	set := make([]bool, len(l.Text))
+	groups := map[int]bool{}
+	for i, o := range l.Origin {
+		if o >= 0 {
+			out[o] = ref[i] && (out[o] || !set[o])
+			set[o] = true
+		} else if !ref[i] {
+			groups[-o-1] = true // group has a difference
+		}
+	}
+	for i := 1; i < len(out); i++ {
+		if !set[i] {
+			out[i] = out[i-1]
+		}
+	}
+	for i, g := range l.Mask {
+		if g >= 0 {
+			out[i] = !groups[g]
+		}
+	}
+	return out
+}
+
+// mapLineDiffs maps the character differences between two lines.
+// The comparison is done on the normalized text and the maps are for
+// the original text.
+func mapLineDiffs(opts options, l1, l2 lineType) (refa, refb []bool) {
+	refa, refb = mapCommonSubStrings(l1.Norm, l2.Norm, tokenizer(opts))
+	refa = l1.project(refa)
+	refb = l2.project(refb)
+	return
+}
+
+// keptLines returns the indices and the normalized text of the lines
+// that are compared, the ignored and continuation lines are left out.
+func keptLines(seq []lineType) (keep []int, norms []string) {
+	for i, line := range seq {
+		if !line.Ignored && !line.Continuation {
+			keep = append(keep, i)
+			norms = append(norms, line.Norm)
+		}
+	}
+	return
+}
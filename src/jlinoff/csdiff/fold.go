@@ -0,0 +1,103 @@
+// Unicode case folding and normalization.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// fullFolds are the case foldings that map one character to several.
+// The unicode package only provides the simple foldings.
+var fullFolds = map[rune]string{
+	0x00df: "ss",      // sharp s
+	0x1e9e: "ss",      // capital sharp s
+	0x0130: "i\u0307", // capital I with dot above
+	0xfb00: "ff", 0xfb01: "fi", 0xfb02: "fl", 0xfb03: "ffi", 0xfb04: "ffl",
+	0xfb05: "st", 0xfb06: "st",
+}
+
+// rewrite rewrites the normalized text of a line one piece at a time
+// keeping track of where each byte came from. The next function returns
+// the text that replaces the start of its argument and the number of
+// bytes that it replaces. The new bytes get the origin of the first
+// replaced byte.
+func (l *lineType) rewrite(next func(s string) (string, int)) {
+	origin := l.Origin
+	if origin == nil {
+		origin = make([]int, len(l.Norm))
+		for i := range origin {
+			origin[i] = i
+		}
+	}
+	norm := l.Norm
+	b := strings.Builder{}
+	newOrigin := make([]int, 0, len(origin))
+	for i := 0; i < len(norm); {
+		r, n := next(norm[i:])
+		b.WriteString(r)
+		for k := 0; k < len(r); k++ {
+			newOrigin = append(newOrigin, origin[i])
+		}
+		i += n
+	}
+	if b.String() == norm {
+		return
+	}
+	l.Norm = b.String()
+	l.Origin = newOrigin
+}
+
+// foldNext folds the case of the next character for case insensitive
+// comparisons. Every character in a case orbit, like k, K and the Kelvin
+// sign, folds to the same character.
+func foldNext(s string) (string, int) {
+	r, n := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError {
+		return s[:n], n
+	}
+	if f, ok := fullFolds[r]; ok {
+		return f, n
+	}
+	f := r
+	for c := unicode.SimpleFold(r); c != r; c = unicode.SimpleFold(c) {
+		if c < f {
+			f = c
+		}
+	}
+	return string(unicode.ToLower(f)), n
+}
+
+// normalizeNext returns the next normalization segment of a string in
+// a Unicode normalization form, a starter and the combining characters
+// that follow it. The segments can be normalized separately so the origin
+// of each byte is kept.
+func normalizeNext(f norm.Form) func(s string) (string, int) {
+	return func(s string) (string, int) {
+		var it norm.Iter
+		it.InitString(f, s)
+		seg := it.Next()
+		if it.Pos() == 0 {
+			return s[:1], 1
+		}
+		return string(seg), it.Pos()
+	}
+}
+
+// normalizeCase applies the Unicode normalization and case folding
+// options to a line.
+func (l *lineType) normalizeCase(opts options) {
+	switch opts.Normalize {
+	case "NFC":
+		l.rewrite(normalizeNext(norm.NFC))
+	case "NFKC":
+		l.rewrite(normalizeNext(norm.NFKC))
+	}
+	if opts.IgnoreCase {
+		l.rewrite(foldNext)
+	}
+}
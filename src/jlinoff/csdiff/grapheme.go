@@ -0,0 +1,105 @@
+// Grapheme cluster segmentation.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// graphemes splits a string into user perceived characters (extended
+// grapheme clusters). It implements the common rules from Unicode
+// Standard Annex #29 using the tables in the unicode package: CR LF,
+// combining marks, spacing marks, variation selectors, emoji modifiers,
+// zero width joiner sequences, regional indicator pairs and Hangul
+// syllable sequences. Invalid UTF-8 bytes are separate clusters.
+func graphemes(s string) []string {
+	clusters := []string{}
+	start := 0
+	prev := rune(-1)
+	nri := 0 // number of consecutive regional indicators
+	for i := 0; i < len(s); {
+		r, w := utf8.DecodeRuneInString(s[i:])
+		if i > start && graphemeBreak(prev, r, nri) {
+			clusters = append(clusters, s[start:i])
+			start = i
+		}
+		if isRegionalIndicator(r) {
+			nri++
+		} else {
+			nri = 0
+		}
+		if r == utf8.RuneError && w == 1 {
+			prev = -1 // never join an invalid byte
+		} else {
+			prev = r
+		}
+		i += w
+	}
+	if start < len(s) {
+		clusters = append(clusters, s[start:])
+	}
+	return clusters
+}
+
+// graphemeBreak reports whether there is a cluster boundary between
+// prev and r. The nri argument is the number of consecutive regional
+// indicators up to and including prev.
+func graphemeBreak(prev, r rune, nri int) bool {
+	switch {
+	case prev < 0:
+		return true
+	case prev == '\r' && r == '\n':
+		return false
+	case prev == '\r' || prev == '\n' || unicode.IsControl(prev):
+		return true
+	case r == '\r' || r == '\n' || unicode.IsControl(r):
+		return true
+	case isGraphemeExtend(r):
+		return false
+	case prev == 0x200d && isPictographic(r):
+		return false // zero width joiner sequence
+	case isRegionalIndicator(prev) && isRegionalIndicator(r):
+		return nri%2 == 0 // flags are pairs
+	}
+	return !hangulJoins(prev, r)
+}
+
+// isGraphemeExtend reports whether r extends the previous cluster.
+func isGraphemeExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == 0x200d || // zero width joiner
+		(r >= 0x1f3fb && r <= 0x1f3ff) || // emoji modifiers
+		(r >= 0xe0020 && r <= 0xe007f) // emoji tags
+}
+
+// isPictographic reports whether r is an emoji or pictographic symbol.
+func isPictographic(r rune) bool {
+	return unicode.Is(unicode.So, r) || (r >= 0x1f000 && r <= 0x1faff)
+}
+
+// isRegionalIndicator reports whether r is a regional indicator symbol.
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// hangulJoins reports whether two Hangul jamo or syllables are part of
+// the same syllable block.
+func hangulJoins(prev, r rune) bool {
+	isL := func(r rune) bool { return (r >= 0x1100 && r <= 0x115f) || (r >= 0xa960 && r <= 0xa97c) }
+	isV := func(r rune) bool { return (r >= 0x1160 && r <= 0x11a7) || (r >= 0xd7b0 && r <= 0xd7c6) }
+	isT := func(r rune) bool { return (r >= 0x11a8 && r <= 0x11ff) || (r >= 0xd7cb && r <= 0xd7fb) }
+	isSyllable := func(r rune) bool { return r >= 0xac00 && r <= 0xd7a3 }
+	isLV := func(r rune) bool { return isSyllable(r) && (r-0xac00)%28 == 0 }
+	isLVT := func(r rune) bool { return isSyllable(r) && (r-0xac00)%28 != 0 }
+	switch {
+	case isL(prev):
+		return isL(r) || isV(r) || isSyllable(r)
+	case isLV(prev) || isV(prev):
+		return isV(r) || isT(r)
+	case isLVT(prev) || isT(prev):
+		return isT(r)
+	}
+	return false
+}
@@ -10,13 +10,16 @@ import (
 
 // help for program usage
 //    Diff two files side by side allowing for colorized output using
-//    the longest common subsequence algorithm for the line comparisons
+//    the Myers O(ND) longest common subsequence algorithm for the line comparisons
 //    and a recursive longest common substring algorithm for the
 //    character differences between two lines.
 func help() {
 	f := `
 USAGE
    %[1]v [OPTIONS] FILE1 FILE2
+   %[1]v [OPTIONS] -R DIR1 DIR2
+   %[1]v [OPTIONS] --diff3 BASE LEFT RIGHT
+   %[1]v rules test FILE
 
 DESCRIPTION
     Command line tool that does a side by side diff of two text files
@@ -25,6 +28,12 @@ DESCRIPTION
     It is useful for analyzing text files that have patterns like
     timestamps that can easily be filtered out.
 
+    The character differences are found for user perceived characters
+    (grapheme clusters) so UTF-8 text like accented names, combining
+    marks, CJK text and emoji flags are highlighted correctly. The
+    side by side columns are laid out by display width so wide CJK
+    characters and zero width combining marks stay aligned.
+
     The file output is side by side. Here is a simple example. The
     width was truncated to 90 characters. Normally the width is the
     size of the terminal window.
@@ -52,7 +61,8 @@ DESCRIPTION
         $ %[1]v -w 90 f1.txt f2.txt
 
                test/file04a.txt                              test/file05a.txt
-             1 [47msta[0mr[47mtm[0m                                [31;1m|[0m     1 [47mp[0mr[47mefix[0m
+             1 [47mstart[0m                                [31;1m<[0m
+                                                    [31;1m>[0m      1 [47mprefix[0m
              2 Lorem ipsum dolor sit amet, consect$        2 Lorem ipsum dolor sit amet, consect$
              3 adipiscing elit, sed do eiusmod tem$        3 adipiscing elit, sed do eiusmod tem$
              4 incididunt ut labore et dolore magna        4 incididunt ut labore et dolore magna
@@ -81,7 +91,7 @@ DESCRIPTION
         $ %[1]v -w 90 f1.txt f2.txt
 
                test/file04a.txt                              test/file05a.txt
-             1 prefix                                      1 prefix
+             1 start                                       1 prefix
              2 Lorem ipsum dolor sit amet, consect$        2 Lorem ipsum dolor sit amet, consect$
              3 adipiscing elit, sed do eiusmod tem$        3 adipiscing elit, sed do eiusmod tem$
              4 incididunt ut labore et dolore magna        4 incididunt ut labore et dolore magna
@@ -92,14 +102,39 @@ DESCRIPTION
                                                     [31;1m>[0m      9 [47msuffix[0m
 
     As you can see the first line now matches because start was
-    replaced by prefix.
+    replaced by prefix. The replacements are only used for the
+    comparisons, the original text is always displayed.
 
 OPTIONS
+    -a NAME, --algorithm NAME
+                The line diff algorithm. The default is myers.
+                The available algorithms are.
+
+                   myers      Myers O(ND) shortest edit script.
+                   patience   Anchor on lines that are unique in
+                              both files. Good for source code
+                              where braces and blank lines should
+                              not be matched.
+                   histogram  Anchor on the least frequent common
+                              lines. Similar to patience but it
+                              works well when no lines are unique,
+                              as in log files.
+
     --256       Print the ANSI terminal 256 color table color
                 values for foreground and background and exit.
                 This is useful for determing which extended
                 colors work for your terminals.
 
+    -b, --ignore-space-change
+                Ignore changes in the amount of white space. Runs of
+                white space compare as a single space and trailing
+                white space is ignored. The original lines are
+                displayed.
+
+    -B, --ignore-blank-lines
+                Ignore hunks where all of the changed lines are blank.
+                They are shown as context using the Ignored color.
+
     -c COLOR_VAL, --color-map COLOR_VAL
                 Specify a color value for a diff condition.
                 The syntax is COND=ATTR1[,[ATTR2[,ATTR3]]].
@@ -112,6 +147,17 @@ OPTIONS
                    LeftLineOnly   llo   Only the left line, no right.
                    RightLineOnly  rlo   Only the right line, no left.
                    Symbol         sym   The line diff symbol.
+                   Masked         mk    Text masked by a replacement.
+                                        It is not highlighted unless
+                                        a color is specified.
+                   MovedFrom      mf    Left lines that were moved.
+                   MovedTo        mt    Right lines that were moved.
+                   Ignored        ig    Lines in ignored hunks.
+                   LeftChange     lc    Diff3 lines only changed in
+                                        the left file.
+                   RightChange    rc    Diff3 lines only changed in
+                                        the right file.
+                   Conflict       cf    Diff3 lines changed in both.
 
                 The conditions are case insensitive so diff could be
                 specified as Diff, diff, or d.
@@ -205,14 +251,33 @@ OPTIONS
                 all color map fields to fgDefault.
 
     --config FILE
-                Read a configuration files with color map data. There
-                is one color map specification per line. Blank lines
-                and lines that start with # are ignored. White space
-                is allowed and the values are case insensitive.
+                Read a rules file with color map data, filter rules
+                and named profiles. There is one KEY = VALUE setting
+                per line. Blank lines and lines that start with # are
+                ignored. White space is allowed and the keys are case
+                insensitive.
+
+                The keys are the color map fields and the long option
+                names without the dashes: algorithm, exclude,
+                ignore-all-space, ignore-blank-lines, ignore-case,
+                ignore-lines, ignore-matching-lines,
+                ignore-space-change, ignore-trailing-space, include,
+                mask, moved, normalize, pair-threshold, prefilter,
+                prefilter-left, prefilter-right, rename-threshold,
+                renames, replace, replace-left, replace-multiline,
+                replace-right, token-regex and
+                word-diff. A key without a value turns the option on. The replace values are
+                /PATTERN/REPLACEMENT/ where any character can be used
+                as the delimiter.
+
+                The settings before the first profile are always
+                used. A profile starts with a line that has the
+                profile name in brackets, its settings are only used
+                when it is selected by --profile.
 
                 Here is an example.
 
-                  # This is an example color map file.
+                  # This is an example rules file.
                   cd  = bgLightGrey
                   cm  = bold, fgBlue
                   sym = bold, fgMagenta
@@ -220,17 +285,221 @@ OPTIONS
                   llo = bgLightGrey
                   rlo = bgLightGrey
 
+                  [jenkins-log]
+                  mask = timestamps, durations
+                  replace = |\[Pipeline\] ||
+                  input = [Pipeline] Start
+                  expected = Start
+                  ignore-lines = ^DEBUG
+                  ignore-space-change
+                  ig = dim, fgBlue
+
+                A rule can have examples, an input line followed by an
+                expected line. The examples are only used by the rules
+                test command below. For the ignore-lines and
+                ignore-matching-lines rules the expected value is
+                ignored or kept. Use \n for a new line in the
+                examples of the multiline rules.
+
     -d, --diff  Don't do the side by side diff. Use separate lines.
                 This is similar to the standard diff output. This
                 option always suppresses common lines.
 
+    --diff3 BASE
+               Do a three way diff of the two files against their
+               common ancestor BASE. The usage is:
+
+                   --diff3 BASE LEFT RIGHT
+
+               The lines that are the same in all three files are
+               stable, the lines between them are shown with the
+               LeftChange color if only the left file changed, the
+               RightChange color if only the right file changed and
+               the Conflict color if both files changed differently.
+               The symbol between the panes is < for a left change,
+               > for a right change, = for the same change in both
+               files and ! for a conflict.
+
+               The output has three panes: BASE, LEFT and RIGHT. If
+               -d is specified, the output is a listing like the one
+               from diff3 LEFT BASE RIGHT.
+
+    --exclude GLOB
+               Skip the files and directories that match GLOB when
+               comparing directories with -R. GLOB has the gitignore
+               syntax: a GLOB without a slash matches the name at any
+               level, otherwise it matches the path from the top
+               directory, ** matches any number of directories and a
+               trailing slash only matches directories. It can be
+               specified multiple times.
+
+               The .csdiffignore files at the top of both directories
+               are read as well, one GLOB per line. Blank lines and
+               lines that start with # are skipped and !GLOB compares
+               a path that an earlier GLOB skipped. The number of
+               skipped paths is reported by --summary.
+
+               Example: --exclude '*.o' --exclude .git/
+
+    --explain-rules
+               Report the impact of each replacement rule after the
+               diff. For each rule the report shows the number of
+               substitutions in the left and right files, the number
+               of changed lines that are hidden by the rule and the
+               number of changed lines that are only differences
+               because of the rule. They are found by running the
+               diff again without the rule. It is useful for finding
+               rules that are too broad and hide real differences.
+
     -h, --help  This help message.
 
+    --include GLOB
+               Only compare the files that match GLOB when comparing
+               directories with -R. The directories are still walked
+               unless they are excluded. It can be specified multiple
+               times and has the same syntax as --exclude.
+
+               Example: --include '*.go'
+
+    --list-masks
+               List the built-in masks and their replacement rules.
+
+    --mask NAMES
+               Mask the volatile tokens like timestamps and addresses
+               using the built-in masks. NAMES is a comma separated
+               list of mask names. Each mask is a set of replacement
+               rules like the ones specified by -r, the rules are
+               applied in the order that the options appear on the
+               command line. It can be specified multiple times.
+
+               The masks are: durations, hexaddr, ipv4, ipv6, pids,
+               timestamps, tmppaths and uuids. Use --list-masks to
+               see the rules.
+
+               Example: --mask timestamps,uuids,hexaddr
+
+    --moved    Detect blocks of lines that were removed from one
+               place and added in another. The moved lines are shown
+               using the MovedFrom and MovedTo colors and the line
+               number in the other file is shown as @N in the empty
+               line number column of the side by side output. In the
+               diff output (-d) it is shown after the line as
+               (moved to N) or (moved from N).
+
+    -i, --ignore-case
+               Ignore case differences when comparing lines. Unicode
+               case folding is used so characters like K, k and the
+               Kelvin sign compare equal and ß compares equal to ss.
+               The original lines are displayed.
+
+    -I PATTERN, --ignore-matching-lines PATTERN
+               Ignore the hunks of changed lines where every changed
+               line in both files matches the regular expression
+               PATTERN. A hunk that has any other changed line is
+               shown in full. The ignored hunks are shown using the
+               Ignored color in the side by side output and are not
+               shown in the diff output, they are counted as ignored
+               lines in the summary. It can be specified multiple
+               times.
+
+               Example: -I '^version ' -I '^build id '
+
+    --ignore-lines PATTERN
+               Ignore the lines that match the regular expression
+               PATTERN. The ignored lines are not compared, they are
+               shown with their line numbers using the Ignored color
+               in the side by side output and are not shown in the
+               diff output. The pattern is matched against the
+               original line. It can be specified multiple times.
+
+               Example: --ignore-lines '^DEBUG '
+
     -n, --no-color
                Turn off color mode. This option really isn't useful
                because tools like sdiff are much faster. It was only
                made available for testing.
 
+    -o FILE, --output FILE
+               Merge the files into FILE instead of showing the
+               differences, like sdiff -o. Each hunk of changed lines
+               is shown and you are prompted for the lines to use:
+               l for the left lines, r for the right lines, b for both
+               and e to edit both with $EDITOR (vi if it is not set).
+               L and R use the left or right lines for this and all of
+               the remaining hunks. The common lines and the ignored
+               hunks are taken from the left file. FILE is only
+               written if the merge is finished.
+
+    --normalize FORM
+               Normalize the Unicode text before comparing lines.
+               FORM is NFC or NFKC, the Unicode normalization forms.
+               NFC is the canonical composition so that e followed by
+               a combining acute accent compares equal to é and the
+               combining marks compare equal in any canonical order.
+               NFKC also applies the compatibility mappings like the
+               fullwidth and halfwidth forms, ligatures, superscripts,
+               circled digits and non-breaking spaces. The original
+               lines are displayed.
+
+    --prefilter CMD
+    --prefilter-left CMD
+    --prefilter-right CMD
+               Pipe the files through the command CMD before they are
+               read. It is useful for normalizations that cannot be
+               done with regular expressions like sorting or
+               formatting JSON. The -left and -right variants only
+               filter one file. The command is run by sh, the file is
+               its standard input and {} is replaced by the file name.
+               The file names are still shown in the output. If the
+               command fails, csdiff reports its error and exits.
+
+               Example: --prefilter 'jq -S .'
+
+    --profile NAME
+               Use the settings in the named profile of the rules
+               files read by --config. If no rules file was read, the
+               profiles are read from ~/.csdiffrc. The options on
+               the command line override the profiles, --config can
+               appear before or after --profile.
+
+               Example: --profile jenkins-log
+
+    --pair-threshold PCT
+               The minimum similarity percentage in the range
+               [0..100] for two changed lines to be shown side by
+               side as a change (|). Lines that are not similar
+               enough are shown as only in the left (<) or only in
+               the right (>) so that an inserted line does not shift
+               the following lines. Zero pairs the changed lines by
+               position. The default is 50.
+
+    --renames
+               Detect the files that were renamed or copied when
+               comparing directories with -R. The files that are only
+               in one directory are paired by the similarity of their
+               filtered lines, the percentage of the lines that match.
+               A pair is shown as renamed or copied with its
+               similarity followed by the differences between the
+               files. A file is copied if it is similar to a file that
+               is in both directories. The directories that are only
+               in one side are searched and the files that are not
+               paired are reported one by one.
+
+    --rename-threshold PCT
+               The minimum similarity percentage in the range
+               [0..100] for --renames to pair two files. The default
+               is 50.
+
+    -R, --recursive
+               Compare the directories DIR1 and DIR2 recursively. The
+               files and directories that are only in one of them are
+               reported, the files that are in both are compared using
+               the filters and are reported as identical or shown
+               with a "csdiff FILE1 FILE2" header if they differ. The
+               --summary option adds the number of files and
+               directories in each category and the line data for all
+               of the files.
+
     -r PATTERN REPLACEMENT, --replace PATTERN REPLACEMENT
                Replace regular expression pattern PATTERN with
                REPLACEMENT where PATTERN is a regular expression that
@@ -239,15 +508,91 @@ OPTIONS
 
                    https://github.com/google/re2/wiki/Syntax
 
+               The replacements are only used to compare the lines.
+               The original lines are displayed. Use -c mk=COLOR to
+               highlight the masked text.
+
+    --replace-multiline PATTERN REPLACEMENT
+               Like -r but the pattern is matched against the whole
+               text of each file so that it can match across lines,
+               like a stack trace or a JSON object that is split over
+               several lines. Use (?s) to let . match a new line. The
+               multiline replacements are applied before the other
+               replacements.
+
+               When a match spans several lines, they are compared as
+               a single line using the first line. The other lines are
+               shown with their line numbers, they are changed lines if
+               the first line is changed, otherwise they use the Ignored
+               color.
+
+               Example: --replace-multiline '(?s)\{"id".*?\}' '{JSON}'
+
+    --replace-left PATTERN REPLACEMENT
+    --replace-right PATTERN REPLACEMENT
+               Like -r but the replacement is only done in the left
+               (first) or right (second) file. This is useful when
+               only one of the files has a quirk like a different
+               path prefix. The side replacements are applied before
+               the -r replacements.
+
+               Example: --replace-left '/opt/old/' '/usr/local/'
+
     -s, --suppress
                Suppress common lines.
 
+    --test-rules FILE
+               Same as the rules test FILE subcommand. Test the rules
+               in the rules file FILE and exit. Each
+               rule is compiled and its examples are run, an example
+               is an input line followed by an expected line after the
+               rule. For the replacement rules the expected value is
+               the replaced text, for the ignore rules it is ignored or
+               kept. The invalid rules and the failed examples are
+               reported and the exit status is 1 if there are any.
+
+                   replace  = /\d+ms/DURATION/
+                   input    = took 12ms
+                   expected = took DURATION
+
+    --token-regex PATTERN
+               Compare the changed lines using the tokens matched by
+               the regular expression PATTERN instead of characters.
+               The text between the tokens are also tokens. This is
+               useful for domain specific units like key=value pairs
+               or dotted identifiers. It takes precedence over
+               --word-diff.
+
+                   --token-regex '[^,\s]+'
+                   --token-regex '[\w.]+'
+
+    --take-left
+    --take-right
+               Use the left or right lines for every hunk when
+               merging with -o instead of prompting. This is useful
+               for scripts, for example to accept all of the changes
+               that are not hidden by the filters.
+
     -V, --version
                Print the program version and exit.
 
+    -W, --ignore-all-space
+               Ignore all white space when comparing lines. The
+               original lines are displayed.
+
     -w INT, --width INT
                The width of the output. The default is %[3]v.
 
+    -Z, --ignore-trailing-space
+               Ignore white space at the end of the lines.
+
+    --word-diff
+               Compare the changed lines word by word instead of
+               character by character. The lines are split into
+               words, white space and punctuation and the words that
+               differ are highlighted. This avoids highlighting single
+               characters that happen to match in different words.
+
 EXAMPLES
     # Example 1. help
     $ %[1]v -h
@@ -274,6 +619,25 @@ EXAMPLES
     #            ANSI 256 color terminal tables.
     $ %[1]v --256
 
+    # Example 7: Diff two source files using the patience algorithm.
+    $ %[1]v -a patience file1.go file2.go
+
+    # Example 8: Test the rules in a rules file using their examples.
+    #            It exits with status 1 if any rule is invalid or
+    #            any example failed.
+    $ %[1]v rules test rules.conf
+
+    # Example 9: Merge the files into merged.txt, prompting for each
+    #            hunk.
+    $ %[1]v -o merged.txt file1.txt file2.txt
+
+    # Example 10: Compare two directory trees ignoring timestamps.
+    $ %[1]v -R --mask timestamps -s dir1 dir2
+
+    # Example 11: Compare two directory trees after a refactor that
+    #             moved files around.
+    $ %[1]v -R --renames -d dir1 dir2
+
 VERSION
     v%[2]v
 
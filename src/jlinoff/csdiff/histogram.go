@@ -0,0 +1,87 @@
+// Histogram diff algorithm.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+// histogramMaxChain is the maximum number of occurrences of a line in
+// the left side for it to be considered as an anchor.
+const histogramMaxChain = 64
+
+// histogramMatchPoints returns the match points between the interned
+// sequences a and b in the box a[aLo:aHi], b[bLo:bHi] appended to mp.
+//
+// This is the algorithm used by git and jgit. It is an extension of the
+// patience algorithm that uses the common region whose lines have the
+// fewest occurrences in the left side as the anchor, which means that
+// it still works well when there are no unique lines. The regions on
+// either side of the anchor are processed recursively. If there is no
+// common region with few enough occurrences the Myers algorithm is used
+// for the box.
+func histogramMatchPoints(a, b []int, aLo, aHi, bLo, bHi int, mp [][]int) [][]int {
+	aLo, aHi, bLo, bHi, n, mp := trimMatches(a, b, aLo, aHi, bLo, bHi, mp)
+	if aLo < aHi && bLo < bHi {
+		as, bs, length, ok := histogramAnchor(a, b, aLo, aHi, bLo, bHi)
+		if !ok {
+			mp = myersMatchPoints(a, b, aLo, aHi, bLo, bHi, mp)
+		} else {
+			mp = histogramMatchPoints(a, b, aLo, as, bLo, bs, mp)
+			mp = appendMatches(mp, as, bs, length)
+			mp = histogramMatchPoints(a, b, as+length, aHi, bs+length, bHi, mp)
+		}
+	}
+	return appendMatches(mp, aHi, bHi, n)
+}
+
+// histogramAnchor finds the common region with the lowest occurrence
+// count in a[aLo:aHi]. Ties are broken by choosing the longest region.
+// It returns the start of the region in each side and its length.
+func histogramAnchor(a, b []int, aLo, aHi, bLo, bHi int) (as, bs, length int, ok bool) {
+	// Build the histogram of the left side.
+	positions := map[int][]int{}
+	for i := aLo; i < aHi; i++ {
+		positions[a[i]] = append(positions[a[i]], i)
+	}
+
+	// The right side is scanned once, skipping over the regions that
+	// have already been found.
+	best := histogramMaxChain + 1
+	for j := bLo; j < bHi; {
+		next := j + 1
+		occurs, found := positions[b[j]]
+		if !found || len(occurs) > histogramMaxChain {
+			j = next
+			continue
+		}
+		for _, i := range occurs {
+			// Extend the region in both directions and track the lowest
+			// occurrence count of the lines in it.
+			count := len(occurs)
+			s, t := i, j
+			for s > aLo && t > bLo && a[s-1] == b[t-1] {
+				s--
+				t--
+				if c := len(positions[a[s]]); c < count {
+					count = c
+				}
+			}
+			e, f := i+1, j+1
+			for e < aHi && f < bHi && a[e] == b[f] {
+				if c := len(positions[a[e]]); c < count {
+					count = c
+				}
+				e++
+				f++
+			}
+			if f > next {
+				next = f
+			}
+			if count < best || (count == best && e-s > length) {
+				best = count
+				as, bs, length = s, t, e-s
+				ok = true
+			}
+		}
+		j = next
+	}
+	return
+}
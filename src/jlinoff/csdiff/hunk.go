@@ -0,0 +1,132 @@
+// Hunks of changed lines and the ignored hunks.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import "strings"
+
+// hunkType is a hunk of changed lines, seq1[X1:N1] and seq2[X2:N2].
+// Ignored is true if the lines in the hunk are not differences.
+type hunkType struct {
+	X1      int
+	N1      int
+	X2      int
+	N2      int
+	Ignored bool
+}
+
+// findHunks finds the hunks of changed lines between the match points.
+// There is an entry for the lines before each match point and one for
+// the lines after the last match point. The hunks are found once so that
+// the diff and side by side output and the summary agree on what was
+// ignored.
+func findHunks(opts options, seq1, seq2 []lineType, mp [][]int) [][]hunkType {
+	hunks := make([][]hunkType, len(mp)+1)
+	x1 := 0
+	x2 := 0
+	for i := 0; i <= len(mp); i++ {
+		n1 := len(seq1)
+		n2 := len(seq2)
+		if i < len(mp) {
+			n1 = mp[i][0]
+			n2 = mp[i][1]
+		}
+		hunks[i] = splitHunk(opts, seq1, seq2, x1, n1, x2, n2)
+		x1 = n1 + 1
+		x2 = n2 + 1
+	}
+	return hunks
+}
+
+// ignoredHunk reports whether the changed lines seq1[x1:n1] and
+// seq2[x2:n2] between two match points are ignored. The lines in an
+// ignored hunk are not differences, they are shown as context using the
+// Ignored color.
+//
+// A hunk is ignored if all of its lines matched an ignore lines pattern,
+// if all of its other lines match an ignore matching lines pattern or if
+// blank lines are ignored and all of its other lines are blank after
+// normalization.
+func ignoredHunk(opts options, seq1, seq2 []lineType, x1, n1, x2, n2 int) bool {
+	if x1 >= n1 && x2 >= n2 {
+		return false
+	}
+	// lambda to check the lines that did not match an ignore lines
+	// pattern. The continuation lines go with their first line.
+	all := func(seq []lineType, x, n int, f func(lineType) bool) bool {
+		for ; x < n; x++ {
+			if !seq[x].Ignored && !seq[x].Continuation && !f(seq[x]) {
+				return false
+			}
+		}
+		return true
+	}
+	none := func(l lineType) bool { return false }
+	if all(seq1, x1, n1, none) && all(seq2, x2, n2, none) {
+		return true
+	}
+	if len(opts.IgnoreMatchingLines) > 0 {
+		matching := func(l lineType) bool {
+			for _, re := range opts.IgnoreMatchingLines {
+				if re.MatchString(l.Text) {
+					return true
+				}
+			}
+			return false
+		}
+		if all(seq1, x1, n1, matching) && all(seq2, x2, n2, matching) {
+			return true
+		}
+	}
+	if opts.IgnoreBlankLines {
+		blank := func(l lineType) bool { return len(strings.TrimSpace(l.Norm)) == 0 }
+		if all(seq1, x1, n1, blank) && all(seq2, x2, n2, blank) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitHunk splits the changed lines between two match points at the
+// ignored lines. Each hunk either only has ignored lines or has no
+// ignored lines. The ignored lines on both sides at the same place are in
+// the same hunk so that they are shown next to each other.
+//
+// The split is only for the display, the other lines are ignored if all
+// of the changed lines between the match points are ignored.
+func splitHunk(opts options, seq1, seq2 []lineType, x1, n1, x2, n2 int) []hunkType {
+	hunks := []hunkType{}
+	ignored := ignoredHunk(opts, seq1, seq2, x1, n1, x2, n2)
+	for x1 < n1 || x2 < n2 {
+		y1 := x1
+		y2 := x2
+		for y1 < n1 && seq1[y1].Ignored {
+			y1++
+		}
+		for y2 < n2 && seq2[y2].Ignored {
+			y2++
+		}
+		h := hunkType{X1: x1, N1: y1, X2: x2, N2: y2, Ignored: true}
+		if y1 == x1 && y2 == x2 {
+			for y1 < n1 && !seq1[y1].Ignored {
+				y1++
+			}
+			for y2 < n2 && !seq2[y2].Ignored {
+				y2++
+			}
+			h = hunkType{X1: x1, N1: y1, X2: x2, N2: y2, Ignored: ignored}
+		}
+		hunks = append(hunks, h)
+		x1 = y1
+		x2 = y2
+	}
+	return hunks
+}
+
+// ignoredOpts returns the options used to print an ignored line.
+// The color for matching lines is replaced by the ignored color.
+func ignoredOpts(opts options) options {
+	iopts := opts
+	iopts.Colors.LinesMatch = opts.Colors.Ignored
+	return iopts
+}
@@ -0,0 +1,136 @@
+// Exclude and include paths in the directory comparison.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// defaultIgnoreFile is the name of the ignore file that is read from the
+// top of each directory for -R.
+const defaultIgnoreFile = ".csdiffignore"
+
+// ignoreRuleType is a path pattern with the gitignore syntax.
+// Negate is set for a !PATTERN rule, DirOnly for a PATTERN/ rule.
+type ignoreRuleType struct {
+	Glob    string
+	Pattern *regexp.Regexp
+	Negate  bool
+	DirOnly bool
+}
+
+// getIgnoreRule converts a gitignore style pattern to a rule.
+//
+// A pattern without a slash matches the name at any level, otherwise it
+// matches the path relative to the top directory. The * and ? wildcards
+// and [...] do not match a slash, ** matches any number of directories.
+// It returns an error if the pattern is invalid.
+func getIgnoreRule(opt string, glob string) (ignoreRuleType, error) {
+	rule := ignoreRuleType{Glob: glob}
+	p := glob
+	if strings.HasPrefix(p, "!") {
+		rule.Negate = true
+		p = p[1:]
+	}
+	if strings.HasSuffix(p, "/") {
+		rule.DirOnly = true
+		p = strings.TrimRight(p, "/")
+	}
+	if len(p) == 0 {
+		return rule, fmt.Errorf("invalid pattern '%v' for %v", glob, opt)
+	}
+	prefix := "^(?:.*/)?"
+	if strings.Contains(p, "/") {
+		prefix = "^"
+		p = strings.TrimPrefix(p, "/")
+	}
+
+	re := ""
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			re += "(?:.*/)?"
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			re += ".*"
+			i++
+		case p[i] == '*':
+			re += "[^/]*"
+		case p[i] == '?':
+			re += "[^/]"
+		case p[i] == '[':
+			j := strings.Index(p[i:], "]")
+			if j < 0 {
+				return rule, fmt.Errorf("invalid pattern '%v' for %v, missing ]", glob, opt)
+			}
+			class := strings.Replace(p[i+1:i+j], `\`, `\\`, -1)
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re += "[" + class + "]"
+			i += j
+		case p[i] == '\\' && i+1 < len(p):
+			i++
+			re += regexp.QuoteMeta(p[i : i+1])
+		default:
+			re += regexp.QuoteMeta(p[i : i+1])
+		}
+	}
+	var err error
+	rule.Pattern, err = getRegexp(opt, prefix+re+"$")
+	return rule, err
+}
+
+// readIgnoreFile reads the rules in an ignore file. Blank lines and
+// lines that start with # are skipped. It returns no rules if the file
+// does not exist.
+func readIgnoreFile(path string) (rules []ignoreRuleType) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return
+	}
+	for i, line := range readLines(path) {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule, err := getIgnoreRule(fmt.Sprintf("%v:%v", path, i+1), line)
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+		rules = append(rules, rule)
+	}
+	return
+}
+
+// matchIgnoreRules reports whether a path relative to the top directory
+// matches the rules. The last rule that matches decides, so a !PATTERN
+// rule can match a path that an earlier rule excluded.
+func matchIgnoreRules(rules []ignoreRuleType, rel string, isDir bool) (match bool) {
+	rel = filepath.ToSlash(rel)
+	for _, r := range rules {
+		if r.DirOnly && !isDir {
+			continue
+		}
+		if r.Pattern.MatchString(rel) {
+			match = !r.Negate
+		}
+	}
+	return
+}
+
+// excludedPath reports whether a path is skipped by the directory
+// comparison. A path is skipped if it matches the exclude rules or if it
+// is a file that does not match the include rules. The directories are
+// always walked unless they are excluded.
+func excludedPath(opts options, rel string, isDir bool) bool {
+	if matchIgnoreRules(opts.Excludes, rel, isDir) {
+		return true
+	}
+	return !isDir && len(opts.Includes) > 0 && !matchIgnoreRules(opts.Includes, rel, isDir)
+}
@@ -1,4 +1,4 @@
-// Program to diff to files to find mismatches using the
+// Program to diff to files to find mismatches using the Myers O(ND)
 // longest common subsequence (LCS) algorithm for line differences
 // and longest common substring for character differences.
 // See the help (-h) for more information.
@@ -6,23 +6,69 @@
 // Copyright (c) 2017 Joe Linoff
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
 
 var version = "0.5.1"
 
 func main() {
+	// The rules test subcommand only takes the rules file so that two
+	// files named rules and test can still be compared.
+	if len(os.Args) == 4 && os.Args[1] == "rules" && os.Args[2] == "test" {
+		rulesCommand(os.Args[3])
+	}
 	opts := getopts()
-	seq1, seq2, mp := diffInit(opts)
+	if len(opts.Base) > 0 {
+		base, left, right, chunks := diff3Init(opts)
+		sum := diff3SummaryType{}
+		if opts.SideBySide {
+			sum.sdiff3(opts, base, left, right, chunks)
+		} else {
+			sum.diff3(opts, base, left, right, chunks)
+		}
+		if opts.Summary {
+			printSummary3(sum)
+		}
+		return
+	}
+	if opts.Recursive && isDir(opts.File1) {
+		sum := diffSummaryType{}
+		dsum := dirSummaryType{}
+		ignores := readIgnoreFile(filepath.Join(opts.File1, defaultIgnoreFile))
+		ignores = append(ignores, readIgnoreFile(filepath.Join(opts.File2, defaultIgnoreFile))...)
+		opts.Excludes = append(ignores, opts.Excludes...)
+		um := unmatchedType{}
+		sum.diffDirs(opts, &dsum, &um, opts.File1, opts.File2)
+		if opts.Renames {
+			sum.diffRenames(opts, &dsum, um)
+		}
+		if opts.Summary {
+			printDirSummary(dsum)
+			printSummary(sum)
+		}
+		return
+	}
+	seq1, seq2, mp, hunks := diffInit(opts)
+	if len(opts.Output) > 0 {
+		merge(opts, seq1, seq2, mp, hunks)
+		return
+	}
 
 	sum := diffSummaryType{}
 	if opts.SideBySide {
-		sum.sdiff(opts, seq1, seq2, mp)
+		sum.sdiff(opts, seq1, seq2, mp, hunks)
 	} else {
-		sum.diff(opts, seq1, seq2, mp)
+		sum.diff(opts, seq1, seq2, mp, hunks)
 	}
 	if opts.Summary {
 		printSummary(sum)
 	}
+	if opts.ExplainRules {
+		explainRules(opts, seq1, seq2, hunks)
+	}
 }
 
 // printSummary prints the diff summary.
@@ -41,4 +87,5 @@ func printSummary(sum diffSummaryType) {
 	fct("summary: NumRightOnlyLines", sum.NumRightOnlyLines)
 	fct("summary: NumRightCharsDiff", sum.NumRightCharsDiff)
 	fct("summary: NumRightCharsMatch", sum.NumRightCharsMatch)
+	fct("summary: NumLinesIgnored", sum.NumLinesIgnored)
 }
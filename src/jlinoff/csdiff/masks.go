@@ -0,0 +1,169 @@
+// Built-in masks for the common volatile tokens.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maskType describes a named set of replacement rules that mask a class
+// of volatile tokens like timestamps or addresses. Each token is replaced
+// by a placeholder so that the tokens compare equal.
+type maskType struct {
+	Name  string
+	Desc  string
+	Rules []replaceType
+}
+
+// hexRE matches a group of hex digits in an IPv6 address.
+const hexRE = `[0-9a-fA-F]{1,4}`
+
+// masks is the list of the built-in masks.
+var masks = []maskType{
+	{
+		Name: "timestamps",
+		Desc: "ISO 8601 and syslog timestamps, dates and times of day.",
+		Rules: []replaceType{
+			{
+				Pattern:     regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`),
+				Replacement: "TIMESTAMP",
+			},
+			{
+				Pattern:     regexp.MustCompile(`\b(?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) +\d{1,2} \d{2}:\d{2}:\d{2}\b`),
+				Replacement: "TIMESTAMP",
+			},
+			{
+				Pattern:     regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}\b`),
+				Replacement: "DATE",
+			},
+			{
+				Pattern:     regexp.MustCompile(`\b\d{2}:\d{2}:\d{2}(?:[.,]\d+)?\b`),
+				Replacement: "TIME",
+			},
+		},
+	},
+	{
+		Name: "uuids",
+		Desc: "UUIDs like 123e4567-e89b-12d3-a456-426614174000.",
+		Rules: []replaceType{
+			{
+				Pattern:     regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`),
+				Replacement: "UUID",
+			},
+		},
+	},
+	{
+		Name: "hexaddr",
+		Desc: "Hex addresses and pointers like 0x7ffd5e8c.",
+		Rules: []replaceType{
+			{
+				Pattern:     regexp.MustCompile(`\b0[xX][0-9a-fA-F]{4,16}\b`),
+				Replacement: "0xADDR",
+			},
+		},
+	},
+	{
+		Name: "pids",
+		Desc: "Process ids like pid=1234, pid 1234 and sshd[1234].",
+		Rules: []replaceType{
+			{
+				Pattern:     regexp.MustCompile(`\b((?i:pid)(?:[=:] ?| ))\d+\b`),
+				Replacement: "${1}PID",
+			},
+			{
+				Pattern:     regexp.MustCompile(`(\w)\[\d+\]`),
+				Replacement: "${1}[PID]",
+			},
+		},
+	},
+	{
+		Name: "ipv4",
+		Desc: "IPv4 addresses like 192.168.1.10.",
+		Rules: []replaceType{
+			{
+				Pattern:     regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`),
+				Replacement: "IPV4",
+			},
+		},
+	},
+	{
+		Name: "ipv6",
+		Desc: "IPv6 addresses like fe80::1ff:fe23:4567:890a and ::1.",
+		Rules: []replaceType{
+			{
+				Pattern:     regexp.MustCompile(`\b(?:` + hexRE + `:){7}` + hexRE + `\b`),
+				Replacement: "IPV6",
+			},
+			{
+				Pattern:     regexp.MustCompile(`\b(?:` + hexRE + `:)+(?::` + hexRE + `)+\b`),
+				Replacement: "IPV6",
+			},
+			{
+				Pattern:     regexp.MustCompile(`(^|[\s\[(=/])::` + hexRE + `(?::` + hexRE + `)*\b`),
+				Replacement: "${1}IPV6",
+			},
+		},
+	},
+	{
+		Name: "durations",
+		Desc: "Durations like 12ms, 1.5s, 1h2m3s and 3 seconds.",
+		Rules: []replaceType{
+			{
+				Pattern:     regexp.MustCompile(`\b(?:\d+(?:\.\d+)?(?:ns|us|µs|ms|s|m|h))+\b`),
+				Replacement: "DURATION",
+			},
+			{
+				Pattern:     regexp.MustCompile(`\b\d+(?:\.\d+)? ?(?:(?:nano|micro|milli)?seconds?|(?:m|u|n)?secs?|minutes?|mins?|hours?|hrs?)\b`),
+				Replacement: "DURATION",
+			},
+		},
+	},
+	{
+		Name: "tmppaths",
+		Desc: "Temporary file paths under /tmp, /var/tmp, /var/folders and the Windows Temp directory.",
+		Rules: []replaceType{
+			{
+				Pattern:     regexp.MustCompile(`(?:/private)?(?:/tmp|/var/tmp|/var/folders)/[^\s:'"]+`),
+				Replacement: "TMPPATH",
+			},
+			{
+				Pattern:     regexp.MustCompile(`(?i)\b[a-z]:\\Users\\[^\\\s]+\\AppData\\Local\\Temp\\[^\s:'"]+`),
+				Replacement: "TMPPATH",
+			},
+		},
+	},
+}
+
+// getMask returns the mask with the specified name.
+func getMask(name string) (maskType, bool) {
+	for _, mask := range masks {
+		if mask.Name == strings.ToLower(name) {
+			return mask, true
+		}
+	}
+	return maskType{}, false
+}
+
+// getMaskNames returns the sorted list of mask names.
+func getMaskNames() (names []string) {
+	for _, mask := range masks {
+		names = append(names, mask.Name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// listMasks prints the built-in masks and their rules.
+func listMasks() {
+	for _, name := range getMaskNames() {
+		mask, _ := getMask(name)
+		fmt.Printf("%-12s %v\n", mask.Name, mask.Desc)
+		for _, rule := range mask.Rules {
+			fmt.Printf("%-12s   -r '%v' '%v'\n", "", rule.Pattern, rule.Replacement)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Merge the files into an output file.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// merge merges the two files and writes the result to the output file.
+//
+// For each hunk of changed lines the left lines, the right lines, both or
+// an edited version of them are written. The take policy chooses the side
+// for every hunk, if there is no policy the user is prompted for each
+// one. The common lines and the ignored hunks are written using the left
+// file. The output file is only written if the merge is finished.
+func merge(opts options, seq1, seq2 []lineType, mp [][]int, hunks [][]hunkType) {
+	out := []string{}
+	take := opts.Take
+	stdin := bufio.NewReader(os.Stdin)
+
+	// lambda to append lines to the output.
+	appendLines := func(seq []lineType, x, n int) {
+		for ; x < n; x++ {
+			out = append(out, seq[x].Text)
+		}
+	}
+
+	for i, interval := range hunks {
+		for _, h := range interval {
+			if h.Ignored {
+				appendLines(seq1, h.X1, h.N1)
+				continue
+			}
+			choice := take
+			for len(choice) == 0 {
+				choice = promptHunk(opts, stdin, seq1, seq2, h)
+				switch choice {
+				case "L":
+					take = "l"
+					choice = take
+				case "R":
+					take = "r"
+					choice = take
+				case "l", "r", "b", "e":
+				default:
+					fmt.Println("l: use the left lines")
+					fmt.Println("r: use the right lines")
+					fmt.Println("b: use both, the left lines followed by the right lines")
+					fmt.Println("e: edit both and use the edited lines")
+					fmt.Println("L: use the left lines for this and all remaining hunks")
+					fmt.Println("R: use the right lines for this and all remaining hunks")
+					choice = ""
+				}
+			}
+			switch choice {
+			case "l":
+				appendLines(seq1, h.X1, h.N1)
+			case "r":
+				appendLines(seq2, h.X2, h.N2)
+			case "b":
+				appendLines(seq1, h.X1, h.N1)
+				appendLines(seq2, h.X2, h.N2)
+			case "e":
+				out = append(out, editHunk(seq1, seq2, h)...)
+			}
+		}
+		if i < len(mp) {
+			out = append(out, seq1[mp[i][0]].Text)
+		}
+	}
+
+	fp, err := os.Create(opts.Output)
+	check(err)
+	defer fp.Close()
+	w := bufio.NewWriter(fp)
+	for _, line := range out {
+		fmt.Fprintln(w, line)
+	}
+	check(w.Flush())
+}
+
+// promptHunk shows a hunk like the diff output and prompts for the lines
+// to use. It returns the first character of the answer.
+func promptHunk(opts options, stdin *bufio.Reader, seq1, seq2 []lineType, h hunkType) string {
+	// lambda to format a range of line numbers.
+	lines := func(x, n int) string {
+		if n-x == 1 {
+			return fmt.Sprintf("%v", n)
+		}
+		return fmt.Sprintf("%v,%v", x+1, n)
+	}
+	fmt.Printf("%vc%v\n", lines(h.X1, h.N1), lines(h.X2, h.N2))
+	for x := h.X1; x < h.N1; x++ {
+		printSymbol(opts, "< ")
+		printLine(opts, -1, seq1[x], -1, true, []bool{}, false)
+		fmt.Println("")
+	}
+	fmt.Println("---")
+	for x := h.X2; x < h.N2; x++ {
+		printSymbol(opts, "> ")
+		printLine(opts, -1, seq2[x], -1, false, []bool{}, false)
+		fmt.Println("")
+	}
+	fmt.Print("merge [l,r,b,e,L,R,?]: ")
+	answer, err := stdin.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if err == io.EOF && len(answer) == 0 {
+		log.Fatalf("ERROR: merge aborted, '%v' was not written", opts.Output)
+	}
+	if len(answer) == 0 {
+		return "?"
+	}
+	return answer[:1]
+}
+
+// editHunk lets the user edit the left lines followed by the right lines
+// of a hunk using the editor in the EDITOR environment variable, vi is
+// used if it is not set. It returns the edited lines.
+func editHunk(seq1, seq2 []lineType, h hunkType) []string {
+	fp, err := ioutil.TempFile("", "csdiff-merge-")
+	check(err)
+	defer os.Remove(fp.Name())
+	for x := h.X1; x < h.N1; x++ {
+		fmt.Fprintln(fp, seq1[x].Text)
+	}
+	for x := h.X2; x < h.N2; x++ {
+		fmt.Fprintln(fp, seq2[x].Text)
+	}
+	check(fp.Close())
+
+	editor := os.Getenv("EDITOR")
+	if len(editor) == 0 {
+		editor = "vi"
+	}
+	c := exec.Command("sh", "-c", editor+" \"$1\"", "sh", fp.Name())
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Run(); err != nil {
+		log.Fatalf("ERROR: editor '%v' failed: %v", editor, err)
+	}
+	return readLines(fp.Name())
+}
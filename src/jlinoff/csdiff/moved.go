@@ -0,0 +1,102 @@
+// Moved block detection.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import "strings"
+
+// movedMinLines is the minimum number of lines in a moved block.
+const movedMinLines = 2
+
+// movedMaxChain is the maximum number of added lines that have the same
+// text as a removed line for it to start a moved block.
+const movedMaxChain = 64
+
+// findMoves finds the blocks of lines that were removed from one place
+// and added in another. The normalized text is compared. For each line
+// it returns the index of the line in the other file that it moved to
+// or from, or -1 if the line was not moved.
+//
+// A block must have at least movedMinLines lines and at least one line
+// that is not blank. The longest block is chosen for each removed line.
+func findMoves(seq1, seq2 []lineType, mp [][]int) (moved1, moved2 []int) {
+	moved1 = make([]int, len(seq1))
+	moved2 = make([]int, len(seq2))
+	for i := range moved1 {
+		moved1[i] = -1
+	}
+	for j := range moved2 {
+		moved2[j] = -1
+	}
+
+	// The removed and added lines are the ones that are not matched or
+	// ignored.
+	removed := make([]bool, len(seq1))
+	added := make([]bool, len(seq2))
+	for i := range removed {
+		removed[i] = !seq1[i].Ignored && !seq1[i].Continuation
+	}
+	for j := range added {
+		added[j] = !seq2[j].Ignored && !seq2[j].Continuation
+	}
+	for _, p := range mp {
+		removed[p[0]] = false
+		added[p[1]] = false
+	}
+	candidates := map[string][]int{}
+	for j, line := range seq2 {
+		if added[j] {
+			candidates[line.Norm] = append(candidates[line.Norm], j)
+		}
+	}
+
+	for i := 0; i < len(seq1); {
+		if !removed[i] || len(candidates[seq1[i].Norm]) > movedMaxChain {
+			i++
+			continue
+		}
+
+		// Find the longest block that starts at this line.
+		best := 0
+		bestJ := -1
+		for _, j := range candidates[seq1[i].Norm] {
+			n := 0
+			for i+n < len(seq1) && j+n < len(seq2) &&
+				removed[i+n] && added[j+n] && moved2[j+n] < 0 &&
+				seq1[i+n].Norm == seq2[j+n].Norm {
+				n++
+			}
+			if n > best {
+				best = n
+				bestJ = j
+			}
+		}
+
+		blank := true
+		for k := 0; k < best; k++ {
+			if len(strings.TrimSpace(seq1[i+k].Norm)) > 0 {
+				blank = false
+				break
+			}
+		}
+		if best < movedMinLines || blank {
+			i++
+			continue
+		}
+		for k := 0; k < best; k++ {
+			moved1[i+k] = bestJ + k
+			moved2[bestJ+k] = i + k
+		}
+		i += best
+	}
+	return
+}
+
+// movedOpts returns the options used to print a line that was moved.
+// The line only color for the side is replaced by the moved color.
+func movedOpts(opts options) options {
+	mopts := opts
+	mopts.Colors.LeftLineOnly = opts.Colors.MovedFrom
+	mopts.Colors.RightLineOnly = opts.Colors.MovedTo
+	return mopts
+}
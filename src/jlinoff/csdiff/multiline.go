@@ -0,0 +1,130 @@
+// Multiline replacements.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// replaceText applies the multiline replacements to the whole text of a
+// file and splits the result back into lines.
+//
+// The lines are joined with new lines so the patterns can match across
+// line boundaries, use (?s) to let . match a new line. When a match
+// removes the new lines between several lines, the normalized text of
+// all of them is compared as part of the first line. The other lines are
+// continuation lines, they are not compared but they are still displayed
+// with their original line numbers as part of the hunk of the first line.
+// Only the original new lines split the text, new lines in the
+// replacement text do not.
+func replaceText(reps []replaceType, lines []string) []lineType {
+	newLines := make([]lineType, len(lines))
+	for i, line := range lines {
+		newLines[i] = lineType{Text: line, Norm: line}
+	}
+	if len(reps) == 0 || len(lines) == 0 {
+		return newLines
+	}
+
+	// The offset of the start of each line in the text.
+	starts := make([]int, len(lines))
+	for i := 1; i < len(lines); i++ {
+		starts[i] = starts[i-1] + len(lines[i-1]) + 1
+	}
+	text := lineType{Text: strings.Join(lines, "\n")}
+	text.Norm = text.Text
+	text.replace(reps)
+	if text.Origin == nil {
+		return newLines
+	}
+
+	// lambda to convert a line of the normalized text, norm[a:b], whose
+	// first original line is k.
+	convert := func(k int, a int, b int) {
+		start := starts[k]
+		end := start + len(lines[k])
+		mask := text.Mask[start:end]
+		if end < len(text.Text) {
+			mask = text.Mask[start : end+1] // include the new line
+		}
+		changed := b-a != end-start
+		for _, g := range mask {
+			if g >= 0 {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return
+		}
+		l := &newLines[k]
+		l.Mask = append([]int{}, text.Mask[start:end]...)
+
+		// The bytes that came from the later lines are part of the
+		// replacement that removed the new line at the end of this one.
+		l.Norm = text.Norm[a:b]
+		l.Origin = make([]int, b-a)
+		for i, o := range text.Origin[a:b] {
+			switch {
+			case o >= start && o < end:
+				l.Origin[i] = o - start
+			case o >= 0:
+				l.Origin[i] = -(text.Mask[end] + 1)
+			default:
+				l.Origin[i] = o
+			}
+		}
+	}
+
+	// lambda to make a line a continuation line.
+	continuation := func(k int) {
+		start := starts[k]
+		l := &newLines[k]
+		l.Norm = ""
+		l.Continuation = true
+		l.Mask = append([]int{}, text.Mask[start:start+len(lines[k])]...)
+	}
+
+	// Split the normalized text at the original new lines.
+	k := 0
+	a := 0
+	for i := 0; i <= len(text.Norm); i++ {
+		if i < len(text.Norm) && (text.Norm[i] != '\n' || text.Origin[i] < 0) {
+			continue
+		}
+		convert(k, a, i)
+		if i == len(text.Norm) {
+			break
+		}
+
+		// The lines between this one and the one after the new line
+		// are continuation lines.
+		next := sort.SearchInts(starts, text.Origin[i]+1)
+		for j := k + 1; j < next; j++ {
+			continuation(j)
+		}
+		k = next
+		a = i + 1
+	}
+	for j := k + 1; j < len(lines); j++ {
+		continuation(j)
+	}
+	return newLines
+}
+
+// ignoreContinuations ignores the continuation lines whose first line was
+// matched or ignored so that they are shown as context. The others are in
+// the hunk of their first line and they are shown and counted as changed
+// lines.
+func ignoreContinuations(seq []lineType, matched []bool) {
+	head := 0
+	for i := range seq {
+		if !seq[i].Continuation {
+			head = i
+		} else if matched[head] || seq[head].Ignored {
+			seq[i].Ignored = true
+		}
+	}
+}
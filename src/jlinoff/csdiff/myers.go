@@ -0,0 +1,189 @@
+// Myers O(ND) difference algorithm.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+// myersType holds the state for the linear space variant of the Myers
+// O(ND) difference algorithm described in "An O(ND) Difference Algorithm
+// and Its Variations" by Eugene W. Myers (1986).
+//
+// The lines are interned as integers so that each comparison is a single
+// integer compare and the only other storage is the pair of V vectors
+// used to find the middle snake, which are reused for every recursion.
+//
+// Like GNU diff, the search for the middle snake stops when the edit
+// distance reaches the cost limit and the box is split at the point that
+// reached the furthest instead. The result is not always minimal but the
+// time is bounded for large files where most of the lines differ.
+type myersType struct {
+	a    []int
+	b    []int
+	vf   []int // furthest reaching forward x for each diagonal
+	vb   []int // furthest reaching backward y for each diagonal
+	mp   [][]int
+	cost int // the edit distance at which the search stops
+}
+
+// myersMinCost is the minimum cost limit, the small boxes always get a
+// minimal diff.
+const myersMinCost = 256
+
+// myersMatchPoints returns the match points between the interned
+// sequences a and b in the box a[aLo:aHi], b[bLo:bHi] appended to mp.
+// The first entry of each match point is the line index in a and the
+// second entry is the line index in b.
+func myersMatchPoints(a, b []int, aLo, aHi, bLo, bHi int, mp [][]int) [][]int {
+	n := (aHi - aLo) + (bHi - bLo)
+	d := myersType{
+		a:    a,
+		b:    b,
+		vf:   make([]int, n+4),
+		vb:   make([]int, n+4),
+		mp:   mp,
+		cost: 1,
+	}
+
+	// The cost limit is roughly the square root of the size.
+	for i := n; i != 0; i >>= 2 {
+		d.cost <<= 1
+	}
+	if d.cost < myersMinCost {
+		d.cost = myersMinCost
+	}
+	d.compare(aLo, aHi, bLo, bHi)
+	return d.mp
+}
+
+// compare finds the match points in the box a[aLo:aHi], b[bLo:bHi] and
+// appends them to the match point list in increasing order.
+func (d *myersType) compare(aLo, aHi, bLo, bHi int) {
+	aLo, aHi, bLo, bHi, n, mp := trimMatches(d.a, d.b, aLo, aHi, bLo, bHi, d.mp)
+	d.mp = mp
+
+	// If either side is empty there are only insertions or deletions,
+	// otherwise split on the middle snake and recurse.
+	if aLo < aHi && bLo < bHi {
+		x1, y1, x2, y2 := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x1, bLo, y1)
+		d.compare(x1, x2, y1, y2)
+		d.compare(x2, aHi, y2, bHi)
+	}
+
+	// The matching suffix is recorded last to keep the order.
+	d.mp = appendMatches(d.mp, aHi, bHi, n)
+}
+
+// middleSnake finds the middle snake of an optimal path through the box
+// by searching forward from the top left corner and backward from the
+// bottom right corner at the same time until the two searches overlap.
+// It returns the start and end points of the snake. The snake contains
+// at most one insertion or deletion and the two boxes on either side of
+// it each have roughly half of the edit distance of the whole box. If the
+// edit distance reaches the cost limit, it returns an empty snake at the
+// point that is furthest from its corner, see splitPoint.
+func (d *myersType) middleSnake(aLo, aHi, bLo, bHi int) (x1, y1, x2, y2 int) {
+	w := aHi - aLo
+	h := bHi - bLo
+	delta := w - h
+	odd := delta&1 != 0
+	max := (w + h + 1) / 2
+	off := max + 1 // offset so that negative diagonals can be indexed
+	vf := d.vf[:2*max+3]
+	vb := d.vb[:2*max+3]
+	vf[off+1] = aLo
+	vb[off+1] = bHi
+
+	for n := 0; n <= max; n++ {
+		// Forward search, k = x - y relative to the top left corner.
+		for k := n; k >= -n; k -= 2 {
+			var x, px int
+			if k == -n || (k != n && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+				px = x
+			} else {
+				px = vf[off+k-1]
+				x = px + 1
+			}
+			y := bLo + (x - aLo) - k
+			py := y
+			if n != 0 && x == px {
+				py = y - 1
+			}
+			for x < aHi && y < bHi && d.a[x] == d.b[y] {
+				x++
+				y++
+			}
+			vf[off+k] = x
+			c := k - delta
+			if odd && c >= -(n-1) && c <= n-1 && y >= vb[off+c] {
+				return px, py, x, y
+			}
+		}
+
+		// Backward search, c = k - delta relative to the bottom right corner.
+		for c := n; c >= -n; c -= 2 {
+			var y, py int
+			if c == -n || (c != n && vb[off+c-1] > vb[off+c+1]) {
+				y = vb[off+c+1]
+				py = y
+			} else {
+				py = vb[off+c-1]
+				y = py - 1
+			}
+			k := c + delta
+			x := aLo + (y - bLo) + k
+			px := x
+			if n != 0 && y == py {
+				px = x + 1
+			}
+			for x > aLo && y > bLo && d.a[x-1] == d.b[y-1] {
+				x--
+				y--
+			}
+			vb[off+c] = y
+			if !odd && k >= -n && k <= n && x <= vf[off+k] {
+				return x, y, px, py
+			}
+		}
+
+		// The search is too expensive.
+		if n >= d.cost {
+			if x, y, ok := d.splitPoint(aLo, aHi, bLo, bHi, n, off); ok {
+				return x, y, x, y
+			}
+		}
+	}
+
+	// Not reachable, the searches always overlap by the time n is max.
+	return aLo, bLo, aHi, bHi
+}
+
+// splitPoint returns the point on the forward or backward search
+// frontier after n steps that is the furthest from its corner. Only the
+// points inside the box, other than its corners, are used so that both
+// of the boxes that it splits the box into are smaller. It returns false
+// if there is no such point.
+func (d *myersType) splitPoint(aLo, aHi, bLo, bHi, n, off int) (bx, by int, ok bool) {
+	delta := (aHi - aLo) - (bHi - bLo)
+	best := -1
+
+	// lambda to use a point if it is further from its corner.
+	try := func(x, y, progress int) {
+		inside := x >= aLo && x <= aHi && y >= bLo && y <= bHi
+		corner := (x == aLo && y == bLo) || (x == aHi && y == bHi)
+		if inside && !corner && progress > best {
+			bx, by, best, ok = x, y, progress, true
+		}
+	}
+	for k := n; k >= -n; k -= 2 {
+		x := d.vf[off+k]
+		y := bLo + (x - aLo) - k
+		try(x, y, x+y-aLo-bLo)
+	}
+	for c := n; c >= -n; c -= 2 {
+		y := d.vb[off+c]
+		x := aLo + (y - bLo) + c + delta
+		try(x, y, aHi+bHi-x-y)
+	}
+	return
+}
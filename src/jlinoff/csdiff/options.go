@@ -13,7 +13,6 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
-	"unicode/utf8"
 )
 
 // colorsType are the colors used in colorize mode.
@@ -24,6 +23,13 @@ type colorsType struct {
 	LeftLineOnly  string
 	RightLineOnly string
 	Symbol        string // |, <, >
+	Masked        string // text masked by a replacement, optional
+	MovedFrom     string // left lines that moved
+	MovedTo       string // right lines that moved
+	Ignored       string // lines in ignored hunks
+	LeftChange    string // diff3 lines changed only in the left file
+	RightChange   string // diff3 lines changed only in the right file
+	Conflict      string // diff3 lines changed differently in both files
 	Reset         string
 }
 
@@ -34,15 +40,42 @@ type replaceType struct {
 }
 
 type options struct {
-	File1        string
-	File2        string
-	Suppress     bool
-	Width        int
-	Colorize     bool
-	Colors       colorsType
-	SideBySide   bool
-	Summary      bool
-	Replacements []replaceType
+	Algorithm           string
+	File1               string
+	File2               string
+	Suppress            bool
+	Width               int
+	Colorize            bool
+	Colors              colorsType
+	SideBySide          bool
+	Summary             bool
+	ExplainRules        bool
+	Moved               bool
+	Normalize           string
+	IgnoreAllSpace      bool
+	IgnoreBlankLines    bool
+	IgnoreCase          bool
+	IgnoreLines         []*regexp.Regexp
+	IgnoreMatchingLines []*regexp.Regexp
+	IgnoreSpaceChange   bool
+	IgnoreTrailingSpace bool
+	PairThreshold       int
+	WordDiff            bool
+	TokenRegex          *regexp.Regexp
+	Replacements        []replaceType
+	ReplacementsLeft    []replaceType
+	ReplacementsRight   []replaceType
+	ReplacementsText    []replaceType
+	PrefilterLeft       string
+	PrefilterRight      string
+	Base                string
+	Output              string
+	Take                string
+	Recursive           bool
+	Excludes            []ignoreRuleType
+	Includes            []ignoreRuleType
+	Renames             bool
+	RenameThreshold     int
 }
 
 func getopts() (opts options) {
@@ -90,6 +123,12 @@ func getopts() (opts options) {
 	reset, _ := termcolors.ParseColorExpr("clear")
 	def, _ := termcolors.ParseColorExpr("bgLightGrey")
 	symdef, _ := termcolors.ParseColorExpr("red,bold")
+	movedFrom, _ := termcolors.ParseColorExpr("fgMagenta")
+	movedTo, _ := termcolors.ParseColorExpr("fgCyan")
+	ignored, _ := termcolors.ParseColorExpr("dim")
+	leftChange, _ := termcolors.ParseColorExpr("fgBlue")
+	rightChange, _ := termcolors.ParseColorExpr("fgGreen")
+	conflict, _ := termcolors.ParseColorExpr("bold,fgRed")
 	ct := colorsType{
 		CharsMatch:    reset,
 		CharsDiff:     def,
@@ -97,94 +136,253 @@ func getopts() (opts options) {
 		LeftLineOnly:  def,
 		RightLineOnly: def,
 		Symbol:        symdef,
+		MovedFrom:     movedFrom,
+		MovedTo:       movedTo,
+		Ignored:       ignored,
+		LeftChange:    leftChange,
+		RightChange:   rightChange,
+		Conflict:      conflict,
 		Reset:         reset,
 	}
 
 	// Initialize the options structure.
 	opts = options{
-		Width:        int(termcolors.GetTermInfo().Cols),
-		Colorize:     true,
-		Colors:       ct,
-		SideBySide:   true,
-		Replacements: []replaceType{},
+		Algorithm:       algorithms[0].Name,
+		PairThreshold:   50,
+		RenameThreshold: 50,
+		Width:           int(termcolors.GetTermInfo().Cols),
+		Colorize:        true,
+		Colors:          ct,
+		SideBySide:      true,
+		Replacements:    []replaceType{},
 	}
 
-	// Process the CLI arguments.
-	for i := 1; i < len(os.Args); i++ {
-		opt := os.Args[i]
-		switch opt {
-		case "--256":
-			termcolors.Print256ColorTables()
-			os.Exit(0)
-		case "-h", "--help":
-			help()
-		case "-c", "--color-map":
-			cm := nextArg(&i, opt)
-			getColorMap(opt, cm, &opts)
-		case "--clear":
-			clear, _ := termcolors.ParseColorExpr("clear")
-			opts.Colors = colorsType{
-				CharsMatch:    clear,
-				CharsDiff:     clear,
-				LinesMatch:    clear,
-				LeftLineOnly:  clear,
-				RightLineOnly: clear,
-				Symbol:        clear,
-			}
-		case "--config":
-			config := nextArg(&i, opt)
-			readConfig(opt, config, &opts)
-		case "-d", "--diff":
-			opts.SideBySide = false
-		case "-n", "--no-colorize":
-			opts.Colorize = false
-		case "-r", "--replace":
-			p := nextArgN(&i, opt, 1)
-			r := nextArgN(&i, opt, 2)
-			rp, e := regexp.Compile(p)
-			if e != nil {
-				log.Fatalf("invalid regular expression '%v' for %v", p, opt)
-			}
-			replace := replaceType{Pattern: rp, Replacement: r}
-			opts.Replacements = append(opts.Replacements, replace)
-		case "-s", "--suppress-common-lines":
-			opts.Suppress = true
-		case "--summary":
-			opts.Summary = true
-		case "-w", "--width":
-			opts.Width = nextArgInt(&i, opt, 8, 100000)
-		case "-V", "--version":
-			b := filepath.Base(os.Args[0])
-			fmt.Printf("%v v%v\n", b, version)
-			os.Exit(0)
-		default:
-			if len(opts.File1) == 0 {
-				opts.File1 = opt
-				if fi, err := os.Stat(opt); os.IsNotExist(err) {
-					log.Fatalf("file does not exist: '%v'", opt)
-				} else if fi.Mode().IsDir() {
-					log.Fatalf("cannot csdiff a directory: '%v'", opt)
+	// The rules files and the selected profiles are applied to the
+	// defaults so that the options on the command line override them no
+	// matter where they appear. The arguments are parsed twice, the first
+	// pass collects the rules and the profiles in base.
+	base := opts
+	profiles := map[string][]ruleType{}
+	selected := []string{}
+
+	// lambda to exit if an option is invalid.
+	fatal := func(err error) {
+		if err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	}
+
+	// lambda to process the CLI arguments.
+	parse := func(opts *options, first bool) {
+		for i := 1; i < len(os.Args); i++ {
+			opt := os.Args[i]
+			switch opt {
+			case "--256":
+				termcolors.Print256ColorTables()
+				os.Exit(0)
+			case "-a", "--algorithm":
+				a := nextArg(&i, opt)
+				if _, ok := getAlgorithm(a); !ok {
+					log.Fatalf("ERROR: invalid algorithm '%v' for %v, expected one of: %v", a, opt, strings.Join(getAlgorithmNames(), ", "))
+				}
+				opts.Algorithm = strings.ToLower(a)
+			case "-h", "--help":
+				help()
+			case "-b", "--ignore-space-change":
+				opts.IgnoreSpaceChange = true
+			case "-B", "--ignore-blank-lines":
+				opts.IgnoreBlankLines = true
+			case "-c", "--color-map":
+				cm := nextArg(&i, opt)
+				fatal(getColorMap(opt, cm, opts))
+			case "--clear":
+				clear, _ := termcolors.ParseColorExpr("clear")
+				opts.Colors = colorsType{
+					CharsMatch:    clear,
+					CharsDiff:     clear,
+					LinesMatch:    clear,
+					LeftLineOnly:  clear,
+					RightLineOnly: clear,
+					Symbol:        clear,
+					MovedFrom:     clear,
+					MovedTo:       clear,
+					Ignored:       clear,
+					LeftChange:    clear,
+					RightChange:   clear,
+					Conflict:      clear,
 				}
-			} else if len(opts.File2) == 0 {
-				opts.File2 = opt
-				if fi, err := os.Stat(opt); os.IsNotExist(err) {
-					log.Fatalf("file does not exist: '%v'", opt)
+			case "--exclude":
+				rule, err := getIgnoreRule(opt, nextArg(&i, opt))
+				fatal(err)
+				opts.Excludes = append(opts.Excludes, rule)
+			case "--explain-rules":
+				opts.ExplainRules = true
+			case "--config":
+				config := nextArg(&i, opt)
+				if first {
+					readConfig(opt, config, &base, profiles)
+				}
+			case "--diff3":
+				opts.Base = nextArg(&i, opt)
+				if fi, err := os.Stat(opts.Base); os.IsNotExist(err) {
+					log.Fatalf("file does not exist: '%v'", opts.Base)
 				} else if fi.Mode().IsDir() {
-					// If this is a directory, append the basename
-					// of the original file.
-					opts.File2 = path.Join(opt, path.Base(opts.File1))
+					log.Fatalf("cannot csdiff a directory: '%v'", opts.Base)
+				}
+			case "-d", "--diff":
+				opts.SideBySide = false
+			case "--include":
+				rule, err := getIgnoreRule(opt, nextArg(&i, opt))
+				fatal(err)
+				opts.Includes = append(opts.Includes, rule)
+			case "-W", "--ignore-all-space":
+				opts.IgnoreAllSpace = true
+			case "-Z", "--ignore-trailing-space":
+				opts.IgnoreTrailingSpace = true
+			case "--ignore-lines":
+				re, err := getRegexp(opt, nextArg(&i, opt))
+				fatal(err)
+				opts.IgnoreLines = append(opts.IgnoreLines, re)
+			case "-I", "--ignore-matching-lines":
+				re, err := getRegexp(opt, nextArg(&i, opt))
+				fatal(err)
+				opts.IgnoreMatchingLines = append(opts.IgnoreMatchingLines, re)
+			case "--list-masks":
+				listMasks()
+				os.Exit(0)
+			case "--mask":
+				rules, err := getMaskRules(opt, nextArg(&i, opt))
+				fatal(err)
+				opts.Replacements = append(opts.Replacements, rules...)
+			case "--moved":
+				opts.Moved = true
+			case "-i", "--ignore-case":
+				opts.IgnoreCase = true
+			case "--normalize":
+				form, err := getNormalize(opt, nextArg(&i, opt))
+				fatal(err)
+				opts.Normalize = form
+			case "-n", "--no-colorize":
+				opts.Colorize = false
+			case "--pair-threshold":
+				opts.PairThreshold = nextArgInt(&i, opt, 0, 100)
+			case "--prefilter":
+				opts.PrefilterLeft = nextArg(&i, opt)
+				opts.PrefilterRight = opts.PrefilterLeft
+			case "--prefilter-left":
+				opts.PrefilterLeft = nextArg(&i, opt)
+			case "--prefilter-right":
+				opts.PrefilterRight = nextArg(&i, opt)
+			case "--profile":
+				name := nextArg(&i, opt)
+				if first {
+					selected = append(selected, name)
+				}
+			case "-r", "--replace":
+				p := nextArgN(&i, opt, 1)
+				r := nextArgN(&i, opt, 2)
+				re, err := getRegexp(opt, p)
+				fatal(err)
+				replace := replaceType{Pattern: re, Replacement: r}
+				opts.Replacements = append(opts.Replacements, replace)
+			case "--replace-left", "--replace-right":
+				p := nextArgN(&i, opt, 1)
+				r := nextArgN(&i, opt, 2)
+				re, err := getRegexp(opt, p)
+				fatal(err)
+				replace := replaceType{Pattern: re, Replacement: r}
+				if opt == "--replace-left" {
+					opts.ReplacementsLeft = append(opts.ReplacementsLeft, replace)
+				} else {
+					opts.ReplacementsRight = append(opts.ReplacementsRight, replace)
+				}
+			case "--replace-multiline":
+				p := nextArgN(&i, opt, 1)
+				r := nextArgN(&i, opt, 2)
+				re, err := getRegexp(opt, p)
+				fatal(err)
+				replace := replaceType{Pattern: re, Replacement: r}
+				opts.ReplacementsText = append(opts.ReplacementsText, replace)
+			case "-o", "--output":
+				opts.Output = nextArg(&i, opt)
+			case "--renames":
+				opts.Renames = true
+			case "--rename-threshold":
+				opts.RenameThreshold = nextArgInt(&i, opt, 0, 100)
+			case "-R", "--recursive":
+				opts.Recursive = true
+			case "-s", "--suppress-common-lines":
+				opts.Suppress = true
+			case "--summary":
+				opts.Summary = true
+			case "--take-left":
+				opts.Take = "l"
+			case "--take-right":
+				opts.Take = "r"
+			case "-w", "--width":
+				opts.Width = nextArgInt(&i, opt, 8, 100000)
+			case "--test-rules":
+				rulesCommand(nextArg(&i, opt))
+			case "--token-regex":
+				re, err := getRegexp(opt, nextArg(&i, opt))
+				fatal(err)
+				opts.TokenRegex = re
+			case "--word-diff":
+				opts.WordDiff = true
+			case "-V", "--version":
+				b := filepath.Base(os.Args[0])
+				fmt.Printf("%v v%v\n", b, version)
+				os.Exit(0)
+			default:
+				if len(opts.File1) == 0 {
+					opts.File1 = opt
+					if _, err := os.Stat(opt); os.IsNotExist(err) {
+						log.Fatalf("file does not exist: '%v'", opt)
+					}
+				} else if len(opts.File2) == 0 {
+					opts.File2 = opt
+					if _, err := os.Stat(opt); os.IsNotExist(err) {
+						log.Fatalf("file does not exist: '%v'", opt)
+					}
+				} else {
+					log.Fatalf("too many arguments specified")
 				}
-			} else {
-				log.Fatalf("too many arguments specified")
 			}
 		}
 	}
+
+	scratch := base
+	parse(&scratch, true)
+	for _, name := range selected {
+		applyProfile("--profile", name, &base, profiles)
+	}
+	opts = base
+	parse(&opts, false)
+
+	// The directories are only compared by -R, they are checked after
+	// all of the options are parsed because -R can appear anywhere.
+	if isDir(opts.File1) {
+		if !opts.Recursive {
+			log.Fatalf("cannot csdiff a directory without -R: '%v'", opts.File1)
+		} else if !isDir(opts.File2) {
+			log.Fatalf("-R expected two directories: '%v' '%v'", opts.File1, opts.File2)
+		} else if len(opts.Base) > 0 || len(opts.Output) > 0 {
+			log.Fatalf("-R cannot be used with --diff3 or -o")
+		}
+	} else if isDir(opts.File2) {
+		// If this is a directory, append the basename
+		// of the original file.
+		opts.File2 = path.Join(opts.File2, path.Base(opts.File1))
+	}
+	if len(opts.Take) > 0 && len(opts.Output) == 0 {
+		log.Fatalf("ERROR: --take-left and --take-right require -o FILE")
+	}
 	return
 }
 
 // getColorMap gets the color map argument.
 // this is quite complex.
-func getColorMap(opt string, cms string, opts *options) {
+func getColorMap(opt string, cms string, opts *options) error {
 	// The format is:
 	//  -c <target>=attr[,attr][;<target>=attr[,attr]]
 
@@ -192,13 +390,13 @@ func getColorMap(opt string, cms string, opts *options) {
 	for _, cm := range lines {
 		toks := strings.SplitN(cm, "=", 2)
 		if len(toks) < 2 {
-			log.Fatalf("invalid argument for '%v', expected <fld>=<values>: %v", opt, cm)
+			return fmt.Errorf("invalid argument for '%v', expected <fld>=<values>: %v", opt, cm)
 		}
 
 		key := strings.TrimSpace(toks[0]) // for file parsing
 		seq, err := termcolors.ParseColorExpr(toks[1])
 		if err != nil {
-			log.Fatalf("invalid key value '%v' for '%v', see help (-h): %v", key, opt, err)
+			return fmt.Errorf("invalid key value '%v' for '%v', see help (-h): %v", key, opt, err)
 		}
 		switch strings.ToLower(key) {
 		case "charsmatch", "cm":
@@ -213,24 +411,23 @@ func getColorMap(opt string, cms string, opts *options) {
 			opts.Colors.RightLineOnly = seq
 		case "symbol", "sym", "s":
 			opts.Colors.Symbol = seq
+		case "masked", "mk":
+			opts.Colors.Masked = seq
+		case "movedfrom", "mf":
+			opts.Colors.MovedFrom = seq
+		case "movedto", "mt":
+			opts.Colors.MovedTo = seq
+		case "ignored", "ig":
+			opts.Colors.Ignored = seq
+		case "leftchange", "lc":
+			opts.Colors.LeftChange = seq
+		case "rightchange", "rc":
+			opts.Colors.RightChange = seq
+		case "conflict", "cf":
+			opts.Colors.Conflict = seq
 		default:
-			log.Fatalf("invalid key value '%v' for '%v', see help (-h)", key, opt)
-		}
-	}
-}
-
-// readConfig reads the config file.
-func readConfig(opt string, config string, opts *options) {
-	lines := readLines(config)
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if len(line) == 0 {
-			continue
-		}
-		r, _ := utf8.DecodeRuneInString(line[0:])
-		if string(r) == "#" {
-			continue
+			return fmt.Errorf("invalid key value '%v' for '%v', see help (-h)", key, opt)
 		}
-		getColorMap(opt, line, opts)
 	}
+	return nil
 }
@@ -0,0 +1,181 @@
+// Pair the changed lines between match points by similarity.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import "sort"
+
+// pairMaxWork is the maximum number of token comparisons that are used to
+// score the line pairs in a changed region. Larger regions are paired by
+// position.
+const pairMaxWork = 100000000
+
+// pairLines pairs the changed lines seq1[x1:n1] and seq2[x2:n2] between
+// two match points. Each entry has the index of the left line and the
+// index of the right line or -1 if the line is only on one side.
+//
+// The lines are aligned by similarity so that an inserted line does not
+// shift every following pair. Two lines are only paired if their
+// similarity is at least the pair threshold percentage, the pairs that
+// maximize the total similarity are chosen. If the threshold is zero or
+// scoring the region is too much work, the lines are paired by position.
+func pairLines(opts options, seq1, seq2 []lineType, x1, n1, x2, n2 int) [][]int {
+	m := n1 - x1
+	n := n2 - x2
+	if opts.PairThreshold == 0 || m == 0 || n == 0 {
+		return pairByPosition(x1, n1, x2, n2)
+	}
+
+	// Tokenize each line once. The tokens are mapped to integers so that
+	// they are cheap to compare, the sorted copy is used to get an upper
+	// bound of the similarity.
+	tokenize := tokenizer(opts)
+	ids := map[string]int{}
+	tokens := func(seq []lineType, x, n int) (toks [][]int, bags [][]int) {
+		for ; x < n; x++ {
+			t := []int{}
+			for _, s := range tokenize(seq[x].Norm) {
+				id, ok := ids[s]
+				if !ok {
+					id = len(ids)
+					ids[s] = id
+				}
+				t = append(t, id)
+			}
+			b := append([]int{}, t...)
+			sort.Ints(b)
+			toks = append(toks, t)
+			bags = append(bags, b)
+		}
+		return
+	}
+	toks1, bags1 := tokens(seq1, x1, n1)
+	toks2, bags2 := tokens(seq2, x2, n2)
+
+	// Score the line pairs. The LCS is only computed for the pairs whose
+	// common tokens can reach the threshold.
+	threshold := float64(opts.PairThreshold) / 100.0
+	sims := make([][]float64, m)
+	work := 0
+	for i := 0; i < m; i++ {
+		sims[i] = make([]float64, n)
+		for j := 0; j < n; j++ {
+			total := len(toks1[i]) + len(toks2[j])
+			if seq1[x1+i].Norm == seq2[x2+j].Norm || total == 0 {
+				sims[i][j] = 1.0
+				continue
+			}
+			if 200*commonTokens(bags1[i], bags2[j]) < opts.PairThreshold*total {
+				continue
+			}
+			work += len(toks1[i]) * len(toks2[j])
+			if work > pairMaxWork {
+				return pairByPosition(x1, n1, x2, n2)
+			}
+			sims[i][j] = 2.0 * float64(lcsTokens(toks1[i], toks2[j])) / float64(total)
+		}
+	}
+
+	// Score the alignments.
+	// score[i][j] is the best total similarity of the first i left lines
+	// and the first j right lines.
+	score := make([][]float64, m+1)
+	for i := range score {
+		score[i] = make([]float64, n+1)
+	}
+	for i := 1; i <= m; i++ {
+		for j := 1; j <= n; j++ {
+			best := score[i-1][j]
+			if score[i][j-1] > best {
+				best = score[i][j-1]
+			}
+			if s := sims[i-1][j-1]; s >= threshold && score[i-1][j-1]+s > best {
+				best = score[i-1][j-1] + s
+			}
+			score[i][j] = best
+		}
+	}
+
+	// Backtrack from the lower right corner to get the pairs, the left
+	// only lines are reported before the right only lines.
+	i := m
+	j := n
+	pairs := [][]int{}
+	rpairs := [][]int{} // reversed
+	for i > 0 || j > 0 {
+		if i > 0 && j > 0 && sims[i-1][j-1] >= threshold && score[i][j] == score[i-1][j-1]+sims[i-1][j-1] {
+			rpairs = append(rpairs, []int{x1 + i - 1, x2 + j - 1})
+			i--
+			j--
+		} else if j > 0 && (i == 0 || score[i][j] == score[i][j-1]) {
+			rpairs = append(rpairs, []int{-1, x2 + j - 1})
+			j--
+		} else {
+			rpairs = append(rpairs, []int{x1 + i - 1, -1})
+			i--
+		}
+	}
+	for k := len(rpairs) - 1; k >= 0; k-- {
+		pairs = append(pairs, rpairs[k])
+	}
+	return pairs
+}
+
+// pairByPosition pairs the changed lines seq1[x1:n1] and seq2[x2:n2] by
+// their position in the region.
+func pairByPosition(x1, n1, x2, n2 int) [][]int {
+	pairs := [][]int{}
+	for x1 < n1 || x2 < n2 {
+		p := []int{-1, -1}
+		if x1 < n1 {
+			p[0] = x1
+			x1++
+		}
+		if x2 < n2 {
+			p[1] = x2
+			x2++
+		}
+		pairs = append(pairs, p)
+	}
+	return pairs
+}
+
+// commonTokens returns the number of tokens that are in both sorted token
+// lists. It is an upper bound of the length of the LCS.
+func commonTokens(a, b []int) (n int) {
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			n++
+			i++
+			j++
+		}
+	}
+	return
+}
+
+// lcsTokens returns the number of tokens in the longest common
+// subsequence of two token lists. The similarity of two lines is 2*M/T
+// where M is the LCS length and T is the total number of tokens.
+func lcsTokens(ta, tb []int) int {
+	// Only two rows of the LCS matrix are kept.
+	prev := make([]int, len(tb)+1)
+	curr := make([]int, len(tb)+1)
+	for i := 1; i <= len(ta); i++ {
+		for j := 1; j <= len(tb); j++ {
+			if ta[i-1] == tb[j-1] {
+				curr[j] = prev[j-1] + 1
+			} else if prev[j] > curr[j-1] {
+				curr[j] = prev[j]
+			} else {
+				curr[j] = curr[j-1]
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(tb)]
+}
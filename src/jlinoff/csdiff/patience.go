@@ -0,0 +1,95 @@
+// Patience diff algorithm.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import "sort"
+
+// patienceMatchPoints returns the match points between the interned
+// sequences a and b in the box a[aLo:aHi], b[bLo:bHi] appended to mp.
+//
+// The lines that occur exactly once in both sides of the box are used as
+// anchors. The longest increasing subsequence of the anchors is matched
+// and the regions between them are processed recursively. If there are
+// no unique lines the Myers algorithm is used for the box.
+func patienceMatchPoints(a, b []int, aLo, aHi, bLo, bHi int, mp [][]int) [][]int {
+	aLo, aHi, bLo, bHi, n, mp := trimMatches(a, b, aLo, aHi, bLo, bHi, mp)
+	if aLo < aHi && bLo < bHi {
+		anchors := patienceAnchors(a, b, aLo, aHi, bLo, bHi)
+		if len(anchors) == 0 {
+			mp = myersMatchPoints(a, b, aLo, aHi, bLo, bHi, mp)
+		} else {
+			i, j := aLo, bLo
+			for _, anchor := range anchors {
+				mp = patienceMatchPoints(a, b, i, anchor[0], j, anchor[1], mp)
+				mp = append(mp, anchor)
+				i = anchor[0] + 1
+				j = anchor[1] + 1
+			}
+			mp = patienceMatchPoints(a, b, i, aHi, j, bHi, mp)
+		}
+	}
+	return appendMatches(mp, aHi, bHi, n)
+}
+
+// patienceAnchors returns the longest increasing sequence of lines that
+// are unique in both a[aLo:aHi] and b[bLo:bHi].
+func patienceAnchors(a, b []int, aLo, aHi, bLo, bHi int) [][]int {
+	// Count the occurrences, a line is unique if it occurs once on
+	// each side.
+	type countType struct {
+		na, nb int
+		ia, ib int
+	}
+	counts := map[int]*countType{}
+	for i := aLo; i < aHi; i++ {
+		c, ok := counts[a[i]]
+		if !ok {
+			c = &countType{}
+			counts[a[i]] = c
+		}
+		c.na++
+		c.ia = i
+	}
+	for j := bLo; j < bHi; j++ {
+		if c, ok := counts[b[j]]; ok {
+			c.nb++
+			c.ib = j
+		}
+	}
+	unique := [][]int{}
+	for _, c := range counts {
+		if c.na == 1 && c.nb == 1 {
+			unique = append(unique, []int{c.ia, c.ib})
+		}
+	}
+	if len(unique) == 0 {
+		return unique
+	}
+	sort.Slice(unique, func(i, j int) bool { return unique[i][0] < unique[j][0] })
+
+	// Patience sort on the second index to find the longest increasing
+	// subsequence. Each pile holds the index of its top card and each
+	// card points back to the top of the previous pile.
+	piles := []int{}
+	prev := make([]int, len(unique))
+	for k, u := range unique {
+		p := sort.Search(len(piles), func(i int) bool { return unique[piles[i]][1] > u[1] })
+		if p > 0 {
+			prev[k] = piles[p-1]
+		} else {
+			prev[k] = -1
+		}
+		if p == len(piles) {
+			piles = append(piles, k)
+		} else {
+			piles[p] = k
+		}
+	}
+
+	anchors := make([][]int, len(piles))
+	for k, i := piles[len(piles)-1], len(piles)-1; k >= 0; k, i = prev[k], i-1 {
+		anchors[i] = unique[k]
+	}
+	return anchors
+}
@@ -0,0 +1,45 @@
+// External prefilter commands.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// readInput reads the lines of a file. If there is a prefilter command,
+// the file is piped through it and its output is read instead.
+//
+// The command is run by sh so it can be a pipeline like "jq -S . | sort".
+// The file is the standard input of the command and {} in the command is
+// replaced by the quoted file name for commands that need a file name.
+// The command fails if it exits with a non-zero status, its standard
+// error is reported.
+func readInput(path string, cmd string) []string {
+	if len(cmd) == 0 {
+		return readLines(path)
+	}
+	fp, err := os.Open(path)
+	check(err)
+	defer fp.Close()
+
+	quoted := "'" + strings.Replace(path, "'", `'\''`, -1) + "'"
+	c := exec.Command("sh", "-c", strings.Replace(cmd, "{}", quoted, -1))
+	stdout := bytes.Buffer{}
+	stderr := bytes.Buffer{}
+	c.Stdin = fp
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if len(msg) > 0 {
+			log.Fatalf("ERROR: prefilter '%v' failed for '%v': %v\n%v", cmd, path, err, msg)
+		}
+		log.Fatalf("ERROR: prefilter '%v' failed for '%v': %v", cmd, path, err)
+	}
+	return scanLines(&stdout)
+}
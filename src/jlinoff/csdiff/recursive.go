@@ -0,0 +1,292 @@
+// Compare two directory trees.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+)
+
+// dirSummaryType is the summary information for the files in the
+// directories.
+type dirSummaryType struct {
+	NumFilesIdentical int
+	NumFilesDiffer    int
+	NumFilesLeftOnly  int
+	NumFilesRightOnly int
+	NumDirsLeftOnly   int
+	NumDirsRightOnly  int
+	NumPathsExcluded  int
+	NumFilesRenamed   int
+	NumFilesCopied    int
+}
+
+// unmatchedType is the paths relative to the top directories of the files
+// that are only in the left directory, only in the right directory and
+// in both directories.
+type unmatchedType struct {
+	Left   []string
+	Right  []string
+	Common []string
+}
+
+// renameType is a file in the left directory that was renamed or copied
+// to a file in the right directory.
+type renameType struct {
+	Left       string
+	Right      string
+	Similarity int
+	Copy       bool
+}
+
+// diffDirs compares the directories in opts.File1 and opts.File2
+// recursively. The entries that are only in one directory are reported
+// like diff -r, a directory that is only in one side is not walked. The
+// files that are in both are compared using the filters and the ones that
+// differ are shown using the side by side or diff output after a header
+// with their names. The line summary data is for all of the files.
+//
+// The paths that match the --exclude rules or that do not match the
+// --include rules are skipped and counted.
+//
+// If --renames is specified, the files that are only in one directory
+// are not reported, they are collected in um for diffRenames. The
+// directories that are only in one side are walked to collect their
+// files.
+func (sum *diffSummaryType) diffDirs(opts options, dsum *dirSummaryType, um *unmatchedType, dir1, dir2 string) {
+	// lambda to get the entries of a directory.
+	readDir := func(dir string) map[string]bool {
+		fis, err := ioutil.ReadDir(dir)
+		check(err)
+		m := map[string]bool{}
+		for _, fi := range fis {
+			m[fi.Name()] = fi.IsDir()
+		}
+		return m
+	}
+	entries1 := readDir(dir1)
+	entries2 := readDir(dir2)
+	names := []string{}
+	for name := range entries1 {
+		names = append(names, name)
+	}
+	for name := range entries2 {
+		if _, ok := entries1[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		file1 := filepath.Join(dir1, name)
+		file2 := filepath.Join(dir2, name)
+		isDir1, ok1 := entries1[name]
+		isDir2, ok2 := entries2[name]
+		rel, err := filepath.Rel(opts.File1, file1)
+		check(err)
+		if excludedPath(opts, rel, isDir1 || isDir2) {
+			dsum.NumPathsExcluded++
+			continue
+		}
+		switch {
+		case !ok2 && opts.Renames:
+			um.Left = append(um.Left, collectFiles(opts, dsum, file1, rel)...)
+		case !ok1 && opts.Renames:
+			um.Right = append(um.Right, collectFiles(opts, dsum, file2, rel)...)
+		case !ok2:
+			printSymbol(opts, fmt.Sprintf("Only in %v: %v", dir1, name))
+			fmt.Println("")
+			if isDir1 {
+				dsum.NumDirsLeftOnly++
+			} else {
+				dsum.NumFilesLeftOnly++
+			}
+		case !ok1:
+			printSymbol(opts, fmt.Sprintf("Only in %v: %v", dir2, name))
+			fmt.Println("")
+			if isDir2 {
+				dsum.NumDirsRightOnly++
+			} else {
+				dsum.NumFilesRightOnly++
+			}
+		case isDir1 && isDir2:
+			sum.diffDirs(opts, dsum, um, file1, file2)
+		case isDir1 || isDir2:
+			kind := map[bool]string{false: "regular file", true: "directory"}
+			printSymbol(opts, fmt.Sprintf("File %v is a %v while file %v is a %v", file1, kind[isDir1], file2, kind[isDir2]))
+			fmt.Println("")
+			dsum.NumFilesDiffer++
+		default:
+			if opts.Renames {
+				um.Common = append(um.Common, rel)
+			}
+			if sum.diffFiles(opts, file1, file2, fmt.Sprintf("csdiff %v %v", file1, file2)) {
+				dsum.NumFilesDiffer++
+			} else {
+				dsum.NumFilesIdentical++
+				fmt.Printf("Files %v and %v are identical\n", file1, file2)
+			}
+		}
+	}
+}
+
+// diffFiles compares two files. If they differ, the header is printed
+// followed by the side by side or diff output. It reports whether the
+// files differ.
+func (sum *diffSummaryType) diffFiles(opts options, file1, file2 string, header string) bool {
+	opts.File1 = file1
+	opts.File2 = file2
+	seq1, seq2, mp, hunks := diffInit(opts)
+	if changedLines(hunks) == 0 {
+		return false
+	}
+	printSymbol(opts, header)
+	fmt.Println("")
+	if opts.SideBySide {
+		sum.sdiff(opts, seq1, seq2, mp, hunks)
+	} else {
+		sum.diff(opts, seq1, seq2, mp, hunks)
+	}
+	return true
+}
+
+// collectFiles returns the paths relative to the top directory of the
+// files in a directory tree that are not excluded. If the path is a file,
+// it is returned.
+func collectFiles(opts options, dsum *dirSummaryType, path string, rel string) (files []string) {
+	if !isDir(path) {
+		return []string{rel}
+	}
+	fis, err := ioutil.ReadDir(path)
+	check(err)
+	for _, fi := range fis {
+		crel := filepath.Join(rel, fi.Name())
+		if excludedPath(opts, crel, fi.IsDir()) {
+			dsum.NumPathsExcluded++
+			continue
+		}
+		files = append(files, collectFiles(opts, dsum, filepath.Join(path, fi.Name()), crel)...)
+	}
+	return
+}
+
+// diffRenames finds the files that were renamed or copied and compares
+// them. The unmatched files are paired by the similarity of their
+// normalized lines, the percentage of the lines that match. The pairs
+// with the highest similarity are used first and each file is only used
+// once. The empty files are not paired. A right file that is not paired
+// is a copy if it is similar enough to a file that is in both
+// directories. The files that are not paired are reported as only in one
+// directory.
+func (sum *diffSummaryType) diffRenames(opts options, dsum *dirSummaryType, um unmatchedType) {
+	// lambda to get the normalized lines of a file, they are cached
+	// because each file is compared to many others.
+	cache := map[string][]string{}
+	norms := func(root string, rel string, prefilter string, side []replaceType) []string {
+		path := filepath.Join(root, rel)
+		if norm, ok := cache[path]; ok {
+			return norm
+		}
+		_, norm := keptLines(filter(opts, readInput(path, prefilter), side))
+		cache[path] = norm
+		return norm
+	}
+
+	// lambda to get the similarity percentage of two files. The empty
+	// files are never similar, like git, because any two of them would
+	// match. It returns -1 so that they are below any threshold.
+	similarity := func(rel1, rel2 string) int {
+		norm1 := norms(opts.File1, rel1, opts.PrefilterLeft, opts.ReplacementsLeft)
+		norm2 := norms(opts.File2, rel2, opts.PrefilterRight, opts.ReplacementsRight)
+		if len(norm1) == 0 || len(norm2) == 0 {
+			return -1
+		}
+		return 200 * len(matchPoints(opts.Algorithm, norm1, norm2)) / (len(norm1) + len(norm2))
+	}
+
+	candidates := []renameType{}
+	for _, left := range um.Left {
+		for _, right := range um.Right {
+			if s := similarity(left, right); s >= opts.RenameThreshold {
+				candidates = append(candidates, renameType{Left: left, Right: right, Similarity: s})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Similarity > candidates[j].Similarity })
+	renames := []renameType{}
+	used := map[string]bool{}
+	for _, c := range candidates {
+		if !used["<"+c.Left] && !used[">"+c.Right] {
+			used["<"+c.Left] = true
+			used[">"+c.Right] = true
+			renames = append(renames, c)
+		}
+	}
+	for _, right := range um.Right {
+		if used[">"+right] {
+			continue
+		}
+		best := renameType{Right: right, Similarity: -1, Copy: true}
+		for _, left := range um.Common {
+			if s := similarity(left, right); s >= opts.RenameThreshold && s > best.Similarity {
+				best.Left = left
+				best.Similarity = s
+			}
+		}
+		if best.Similarity >= 0 {
+			used[">"+right] = true
+			renames = append(renames, best)
+		}
+	}
+	sort.SliceStable(renames, func(i, j int) bool { return renames[i].Right < renames[j].Right })
+
+	for _, r := range renames {
+		file1 := filepath.Join(opts.File1, r.Left)
+		file2 := filepath.Join(opts.File2, r.Right)
+		header := fmt.Sprintf("Renamed %v -> %v (%v%% similar)", file1, file2, r.Similarity)
+		if r.Copy {
+			header = fmt.Sprintf("Copied %v -> %v (%v%% similar)", file1, file2, r.Similarity)
+			dsum.NumFilesCopied++
+		} else {
+			dsum.NumFilesRenamed++
+		}
+		if !sum.diffFiles(opts, file1, file2, header) {
+			printSymbol(opts, header)
+			fmt.Println("")
+		}
+	}
+
+	// lambda to report the files that are only in one directory.
+	only := func(root string, files []string, prefix string, n *int) {
+		for _, rel := range files {
+			if !used[prefix+rel] {
+				path := filepath.Join(root, rel)
+				printSymbol(opts, fmt.Sprintf("Only in %v: %v", filepath.Dir(path), filepath.Base(path)))
+				fmt.Println("")
+				*n++
+			}
+		}
+	}
+	only(opts.File1, um.Left, "<", &dsum.NumFilesLeftOnly)
+	only(opts.File2, um.Right, ">", &dsum.NumFilesRightOnly)
+}
+
+// printDirSummary prints the directory summary.
+func printDirSummary(dsum dirSummaryType) {
+	fct := func(key string, val int) {
+		fmt.Printf("%-30s : %6d\n", key, val)
+	}
+
+	fct("summary: NumFilesIdentical", dsum.NumFilesIdentical)
+	fct("summary: NumFilesDiffer", dsum.NumFilesDiffer)
+	fct("summary: NumFilesLeftOnly", dsum.NumFilesLeftOnly)
+	fct("summary: NumFilesRightOnly", dsum.NumFilesRightOnly)
+	fct("summary: NumDirsLeftOnly", dsum.NumDirsLeftOnly)
+	fct("summary: NumDirsRightOnly", dsum.NumDirsRightOnly)
+	fct("summary: NumPathsExcluded", dsum.NumPathsExcluded)
+	fct("summary: NumFilesRenamed", dsum.NumFilesRenamed)
+	fct("summary: NumFilesCopied", dsum.NumFilesCopied)
+}
@@ -0,0 +1,297 @@
+// Rules files with named profiles.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultRulesFile is the rules file in the home directory that is read
+// when a profile is selected and no rules file was specified.
+const defaultRulesFile = ".csdiffrc"
+
+// ruleType is a key and value from a rules file. Opt is the file name
+// and line number used in the error messages. Profile is the name of the
+// profile section that it is in and Section is the number of the section,
+// they are empty for the rules before the first profile.
+type ruleType struct {
+	Opt     string
+	Profile string
+	Section int
+	Key     string
+	Value   string
+}
+
+// readConfig reads a rules file.
+//
+// The rules before the first profile section are applied immediately.
+// The rules in a profile section, which starts with a line like
+// [jenkins-log], are saved in profiles and are only applied when the
+// profile is selected. If a profile is defined more than once, the last
+// definition is used.
+func readConfig(opt string, config string, opts *options, profiles map[string][]ruleType) {
+	rules, names := readRules(opt, config)
+	last := map[string]int{}
+	for i, name := range names {
+		profiles[name] = []ruleType{}
+		last[name] = i + 1
+	}
+	for _, r := range rules {
+		if len(r.Profile) > 0 {
+			if r.Section == last[r.Profile] {
+				profiles[r.Profile] = append(profiles[r.Profile], r)
+			}
+		} else if err := applyRule(r, opts); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	}
+}
+
+// readRules reads the rules and the profile names of the sections from a
+// rules file. If a profile is defined more than once, its name is
+// returned for each section and the rules from all of them are returned.
+func readRules(opt string, config string) (rules []ruleType, names []string) {
+	if _, err := os.Stat(config); err != nil {
+		log.Fatalf("ERROR: cannot read rules file '%v' for %v: %v", config, opt, err)
+	}
+	profile := ""
+	for i, line := range readLines(config) {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ropt := fmt.Sprintf("%v:%v", config, i+1)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			profile = strings.TrimSpace(line[1 : len(line)-1])
+			if len(profile) == 0 {
+				log.Fatalf("ERROR: missing profile name at %v", ropt)
+			}
+			names = append(names, profile)
+			continue
+		}
+
+		// A key without a value is a boolean option that is turned on.
+		r := ruleType{Opt: ropt, Profile: profile, Key: line, Value: "true"}
+		if len(profile) > 0 {
+			r.Section = len(names)
+		}
+		if toks := strings.SplitN(line, "=", 2); len(toks) == 2 {
+			r.Key = strings.TrimSpace(toks[0])
+			r.Value = strings.TrimSpace(toks[1])
+		}
+		rules = append(rules, r)
+	}
+	return
+}
+
+// applyProfile applies the rules of a profile. If no rules files were
+// read, the default rules file in the home directory is read.
+func applyProfile(opt string, name string, opts *options, profiles map[string][]ruleType) {
+	if len(profiles) == 0 {
+		config := filepath.Join(os.Getenv("HOME"), defaultRulesFile)
+		if _, err := os.Stat(config); err == nil {
+			readConfig(opt, config, opts, profiles)
+		}
+	}
+	rules, ok := profiles[name]
+	if !ok {
+		names := []string{}
+		for n := range profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			log.Fatalf("ERROR: unknown profile '%v' for %v, no profiles were found, see --config", name, opt)
+		}
+		log.Fatalf("ERROR: unknown profile '%v' for %v, expected one of: %v", name, opt, strings.Join(names, ", "))
+	}
+	for _, r := range rules {
+		if err := applyRule(r, opts); err != nil {
+			log.Fatalf("ERROR: %v", err)
+		}
+	}
+}
+
+// applyRule applies a rule from a rules file. The keys are the long
+// option names without the leading dashes. The colors use the same keys
+// as the color map. It returns an error if the rule is invalid.
+func applyRule(r ruleType, opts *options) (err error) {
+	// lambda to get a boolean value.
+	boolean := func() bool {
+		v, e := strconv.ParseBool(r.Value)
+		if e != nil {
+			err = fmt.Errorf("invalid boolean value '%v' for '%v' at %v", r.Value, r.Key, r.Opt)
+		}
+		return v
+	}
+
+	// lambda to get a percentage.
+	percent := func() int {
+		v, e := strconv.Atoi(r.Value)
+		if e != nil || v < 0 || v > 100 {
+			err = fmt.Errorf("'%v' expected a number in the range [0..100] at %v", r.Key, r.Opt)
+		}
+		return v
+	}
+
+	switch strings.ToLower(r.Key) {
+	case "algorithm":
+		if _, ok := getAlgorithm(r.Value); !ok {
+			return fmt.Errorf("invalid algorithm '%v' at %v, expected one of: %v", r.Value, r.Opt, strings.Join(getAlgorithmNames(), ", "))
+		}
+		opts.Algorithm = strings.ToLower(r.Value)
+	case "exclude":
+		rule, e := getIgnoreRule(r.Opt, r.Value)
+		if e != nil {
+			return e
+		}
+		opts.Excludes = append(opts.Excludes, rule)
+	case "include":
+		rule, e := getIgnoreRule(r.Opt, r.Value)
+		if e != nil {
+			return e
+		}
+		opts.Includes = append(opts.Includes, rule)
+	case "input", "expected":
+		// The examples are only used by the rules test command.
+	case "ignore-all-space":
+		opts.IgnoreAllSpace = boolean()
+	case "ignore-blank-lines":
+		opts.IgnoreBlankLines = boolean()
+	case "ignore-case":
+		opts.IgnoreCase = boolean()
+	case "ignore-lines":
+		re, e := getRegexp(r.Opt, r.Value)
+		if e != nil {
+			return e
+		}
+		opts.IgnoreLines = append(opts.IgnoreLines, re)
+	case "ignore-matching-lines":
+		re, e := getRegexp(r.Opt, r.Value)
+		if e != nil {
+			return e
+		}
+		opts.IgnoreMatchingLines = append(opts.IgnoreMatchingLines, re)
+	case "ignore-space-change":
+		opts.IgnoreSpaceChange = boolean()
+	case "ignore-trailing-space":
+		opts.IgnoreTrailingSpace = boolean()
+	case "mask":
+		rules, e := getMaskRules(r.Opt, r.Value)
+		if e != nil {
+			return e
+		}
+		opts.Replacements = append(opts.Replacements, rules...)
+	case "moved":
+		opts.Moved = boolean()
+	case "normalize":
+		form, e := getNormalize(r.Opt, r.Value)
+		if e != nil {
+			return e
+		}
+		opts.Normalize = form
+	case "pair-threshold":
+		opts.PairThreshold = percent()
+	case "prefilter":
+		opts.PrefilterLeft = r.Value
+		opts.PrefilterRight = r.Value
+	case "prefilter-left":
+		opts.PrefilterLeft = r.Value
+	case "prefilter-right":
+		opts.PrefilterRight = r.Value
+	case "rename-threshold":
+		opts.RenameThreshold = percent()
+	case "renames":
+		opts.Renames = boolean()
+	case "replace":
+		rule, e := getReplaceRule(r.Opt, r.Value)
+		if e != nil {
+			return e
+		}
+		opts.Replacements = append(opts.Replacements, rule)
+	case "replace-left":
+		rule, e := getReplaceRule(r.Opt, r.Value)
+		if e != nil {
+			return e
+		}
+		opts.ReplacementsLeft = append(opts.ReplacementsLeft, rule)
+	case "replace-multiline":
+		rule, e := getReplaceRule(r.Opt, r.Value)
+		if e != nil {
+			return e
+		}
+		opts.ReplacementsText = append(opts.ReplacementsText, rule)
+	case "replace-right":
+		rule, e := getReplaceRule(r.Opt, r.Value)
+		if e != nil {
+			return e
+		}
+		opts.ReplacementsRight = append(opts.ReplacementsRight, rule)
+	case "token-regex":
+		re, e := getRegexp(r.Opt, r.Value)
+		if e != nil {
+			return e
+		}
+		opts.TokenRegex = re
+	case "word-diff":
+		opts.WordDiff = boolean()
+	default:
+		return getColorMap(r.Opt, r.Key+"="+r.Value, opts)
+	}
+	return
+}
+
+// getReplaceRule gets a replacement rule from a rules file.
+// The format is like sed, /PATTERN/REPLACEMENT/, where the first
+// character is the delimiter. The trailing delimiter is optional.
+func getReplaceRule(opt string, value string) (replaceType, error) {
+	if len(value) < 2 {
+		return replaceType{}, fmt.Errorf("invalid replacement '%v' at %v, expected /PATTERN/REPLACEMENT/", value, opt)
+	}
+	d := value[:1]
+	toks := strings.SplitN(value[1:], d, 3)
+	if len(toks) < 2 || (len(toks) == 3 && len(toks[2]) > 0) {
+		return replaceType{}, fmt.Errorf("invalid replacement '%v' at %v, expected %vPATTERN%vREPLACEMENT%v", value, opt, d, d, d)
+	}
+	re, err := getRegexp(opt, toks[0])
+	return replaceType{Pattern: re, Replacement: toks[1]}, err
+}
+
+// getRegexp compiles a regular expression for an option.
+func getRegexp(opt string, p string) (*regexp.Regexp, error) {
+	rp, e := regexp.Compile(p)
+	if e != nil {
+		return nil, fmt.Errorf("invalid regular expression '%v' for %v", p, opt)
+	}
+	return rp, nil
+}
+
+// getMaskRules gets the replacement rules for a comma separated list of
+// mask names.
+func getMaskRules(opt string, names string) (rules []replaceType, err error) {
+	for _, name := range strings.Split(names, ",") {
+		mask, ok := getMask(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("invalid mask '%v' for %v, expected one of: %v", name, opt, strings.Join(getMaskNames(), ", "))
+		}
+		rules = append(rules, mask.Rules...)
+	}
+	return
+}
+
+// getNormalize gets the Unicode normalization form.
+func getNormalize(opt string, form string) (string, error) {
+	form = strings.ToUpper(form)
+	if form != "NFC" && form != "NFKC" {
+		return "", fmt.Errorf("invalid normalization form '%v' for %v, expected NFC or NFKC", form, opt)
+	}
+	return form, nil
+}
@@ -0,0 +1,151 @@
+// Test the rules in a rules file using their examples.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// exampleType is an example input and the expected output for a rule.
+type exampleType struct {
+	Opt      string
+	Input    string
+	Expected string
+}
+
+// unescapeExample converts the \n, \t and \\ escapes in an example so that
+// the examples for multiline rules can be written on one line.
+var unescapeExample = strings.NewReplacer(`\\`, `\`, `\n`, "\n", `\t`, "\t")
+
+// rulesCommand runs the rules test subcommand and exits. The exit status
+// is 1 if any rule is invalid or any example failed.
+//
+//	csdiff rules test FILE
+//	csdiff --test-rules FILE
+func rulesCommand(file string) {
+	if !testRules(file) {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// testRules compiles the rules in a rules file and runs their examples.
+// An example is an input line followed by an expected line after a rule:
+//
+//	replace  = /\d+ms/DURATION/
+//	input    = took 12ms
+//	expected = took DURATION
+//
+// The rules are compiled the same way as when the file is read by
+// --config so a rule that is invalid is reported with its file name and
+// line number, its examples are skipped. The rules in all of the
+// definitions of a profile are tested. For the replacement rules the
+// expected value is the replaced text, for the ignore rules it is
+// "ignored" or "kept". It returns false if any rule is invalid or any
+// example failed.
+func testRules(file string) bool {
+	rules, _ := readRules("rules test", file)
+	passed := 0
+	failed := 0
+	invalid := 0
+	var last *ruleType
+	var example *exampleType
+	nrules := 0
+
+	// lambda to run an example of the last rule. The examples of an
+	// invalid rule are skipped.
+	run := func(e exampleType) {
+		if last == nil {
+			return
+		}
+		actual, ok := runExample(*last, e.Input)
+		if !ok {
+			log.Fatalf("ERROR: examples are not supported for '%v' at %v", last.Key, e.Opt)
+		}
+		if actual == e.Expected {
+			passed++
+			return
+		}
+		failed++
+		profile := ""
+		if len(last.Profile) > 0 {
+			profile = fmt.Sprintf(" [%v]", last.Profile)
+		}
+		fmt.Printf("FAILED: %v%v: %v = %v\n", last.Opt, profile, last.Key, last.Value)
+		fmt.Printf("  example:  %v\n", e.Opt)
+		fmt.Printf("  input:    %q\n", e.Input)
+		fmt.Printf("  expected: %q\n", e.Expected)
+		fmt.Printf("  actual:   %q\n", actual)
+	}
+
+	for i := range rules {
+		r := &rules[i]
+		switch strings.ToLower(r.Key) {
+		case "input":
+			if nrules == 0 {
+				log.Fatalf("ERROR: example input without a rule at %v", r.Opt)
+			} else if example != nil {
+				log.Fatalf("ERROR: example input without an expected value at %v", example.Opt)
+			}
+			example = &exampleType{Opt: r.Opt, Input: unescapeExample.Replace(r.Value)}
+		case "expected":
+			if example == nil {
+				log.Fatalf("ERROR: expected value without an example input at %v", r.Opt)
+			}
+			example.Expected = unescapeExample.Replace(r.Value)
+			run(*example)
+			example = nil
+		default:
+			if example != nil {
+				log.Fatalf("ERROR: example input without an expected value at %v", example.Opt)
+			}
+
+			nrules++
+			last = r
+			opts := options{}
+			if err := applyRule(*r, &opts); err != nil {
+				invalid++
+				last = nil
+				profile := ""
+				if len(r.Profile) > 0 {
+					profile = fmt.Sprintf(" [%v]", r.Profile)
+				}
+				fmt.Printf("FAILED: %v%v: %v = %v\n", r.Opt, profile, r.Key, r.Value)
+				fmt.Printf("  error:    %v\n", err)
+			}
+		}
+	}
+	if example != nil {
+		log.Fatalf("ERROR: example input without an expected value at %v", example.Opt)
+	}
+	fmt.Printf("rules test: %v: %v rules, %v invalid, %v examples, %v passed, %v failed\n", file, nrules, invalid, passed+failed, passed, failed)
+	return failed == 0 && invalid == 0
+}
+
+// runExample runs an example input through a rule. It returns false if
+// the rule does not support examples. The rule must be valid.
+func runExample(r ruleType, input string) (string, bool) {
+	opts := options{}
+	check(applyRule(r, &opts))
+	switch strings.ToLower(r.Key) {
+	case "ignore-lines", "ignore-matching-lines":
+		re := append(opts.IgnoreLines, opts.IgnoreMatchingLines...)[0]
+		if re.MatchString(input) {
+			return "ignored", true
+		}
+		return "kept", true
+	case "mask", "replace", "replace-left", "replace-multiline", "replace-right":
+		reps := append([]replaceType{}, opts.ReplacementsText...)
+		reps = append(reps, opts.ReplacementsLeft...)
+		reps = append(reps, opts.ReplacementsRight...)
+		reps = append(reps, opts.Replacements...)
+		l := lineType{Text: input, Norm: input}
+		l.replace(reps)
+		return l.Norm, true
+	}
+	return "", false
+}
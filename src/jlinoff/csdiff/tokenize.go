@@ -0,0 +1,90 @@
+// Split lines into tokens for the character differences.
+// License: The MIT License (MIT)
+// Copyright (c) 2017 Joe Linoff
+package main
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenizer returns the function that splits a line into the tokens
+// that are compared to find the character differences.
+// The user defined token regex takes precedence over the word diff.
+func tokenizer(opts options) func(string) []string {
+	if opts.TokenRegex != nil {
+		return func(s string) []string {
+			return regexTokens(opts.TokenRegex, s)
+		}
+	} else if opts.WordDiff {
+		return words
+	}
+	return graphemes
+}
+
+// regexTokens splits a string into the tokens matched by a regular
+// expression. The text between the matches is also a token so that
+// every byte of the string is in exactly one token. Empty matches are
+// ignored.
+func regexTokens(re *regexp.Regexp, s string) []string {
+	tokens := []string{}
+	last := 0
+	for _, m := range re.FindAllStringIndex(s, -1) {
+		if m[0] == m[1] {
+			continue
+		}
+		if m[0] > last {
+			tokens = append(tokens, s[last:m[0]])
+		}
+		tokens = append(tokens, s[m[0]:m[1]])
+		last = m[1]
+	}
+	if last < len(s) {
+		tokens = append(tokens, s[last:])
+	}
+	return tokens
+}
+
+// words splits a string into words, runs of white space and single
+// punctuation characters. A word is a run of letters, digits, marks and
+// underscores. Ideographs and kana are separate words because those
+// scripts do not use spaces between words.
+func words(s string) []string {
+	const (
+		other = iota
+		word
+		space
+		ideograph
+	)
+	class := func(c string) int {
+		r, _ := utf8.DecodeRuneInString(c)
+		switch {
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana):
+			return ideograph
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_':
+			return word
+		case unicode.IsSpace(r):
+			return space
+		}
+		return other
+	}
+
+	tokens := []string{}
+	start := 0
+	prev := -1
+	i := 0
+	for _, c := range graphemes(s) {
+		k := class(c)
+		if i > start && (k != prev || k == other || k == ideograph) {
+			tokens = append(tokens, s[start:i])
+			start = i
+		}
+		prev = k
+		i += len(c)
+	}
+	if start < len(s) {
+		tokens = append(tokens, s[start:])
+	}
+	return tokens
+}
@@ -6,6 +6,7 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"runtime"
@@ -20,13 +21,23 @@ func check(e error) {
 }
 
 // truncate string
-// same as s[:w] in python
+// similar to s[:w] in python but w is the display width, the string
+// is only cut between grapheme clusters so it is always valid UTF-8
 func trunc(s string, w int) string {
-	if w < 1 || len(s) <= w {
+	if w < 1 || stringWidth(s) <= w {
 		return s
 	}
-	n := s[:w-1] + "$" // add $ at the end to show that we truncated
-	return n
+	n := 0 // bytes
+	nw := 0
+	for _, c := range graphemes(s) {
+		cw := clusterWidth(c)
+		if nw+cw > w-1 {
+			break
+		}
+		n += len(c)
+		nw += cw
+	}
+	return s[:n] + "$" // add $ at the end to show that we truncated
 }
 
 // debug prints a debug message with the callers
@@ -55,8 +66,19 @@ func readLines(path string) (lines []string) {
 	fp, err := os.Open(path)
 	check(err)
 	defer fp.Close()
+	return scanLines(fp)
+}
 
-	s := bufio.NewScanner(fp)
+// isDir reports whether the path is a directory.
+func isDir(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && fi.Mode().IsDir()
+}
+
+// scanLines reads the lines from a reader.
+func scanLines(r io.Reader) (lines []string) {
+	lines = []string{}
+	s := bufio.NewScanner(r)
 	for s.Scan() {
 		lines = append(lines, s.Text())
 	}
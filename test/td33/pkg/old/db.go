@@ -0,0 +1,4 @@
+package old
+
+// Open opens the database.
+func Open() {}
@@ -0,0 +1,17 @@
+package new
+
+// Max returns the larger value.
+func Max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// Min returns the smaller value.
+func Min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
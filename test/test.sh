@@ -63,8 +63,70 @@ utilsExec ${PROG} --summary --config test.conf td03.txt td04.txt
 utilsExec ${PROG} --summary --diff td03.txt td04.txt
 utilsExec ${PROG} td03.txt td04.txt
 utilsExec ${PROG} -r "'\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2}'" "'yyyy-mm-dd HH:MM:SS'" td03.txt td04.txt
+utilsExec ${PROG} -c mk=fgBlue -r "'\d{4}-\d{2}-\d{2}\s+\d{2}:\d{2}:\d{2}'" "'yyyy-mm-dd HH:MM:SS'" td03.txt td04.txt
+utilsExec ${PROG} -d -r "'\d{2}:(\d{2}):\d{2}'" "'\$1'" td03.txt td04.txt
 utilsExec ${PROG} td02.txt td05.txt
 utilsExec ${PROG} -d td02.txt td05.txt
+utilsExec ${PROG} td06.txt td07.txt
+utilsExec ${PROG} --summary -d td06.txt td07.txt
+utilsExec ${PROG} -w 50 td06.txt td07.txt
+utilsExec ${PROG} -n -w 70 td06.txt td07.txt
+utilsExec ${PROG} --word-diff td01.txt td02.txt
+utilsExec ${PROG} --word-diff -d td06.txt td07.txt
+utilsExec ${PROG} --token-regex "'[\w.:-]+'" td03.txt td04.txt
+utilsExec ${PROG} --pair-threshold 0 td01.txt td02.txt
+utilsExec ${PROG} --pair-threshold 90 -d td02.txt td05.txt
+utilsExec ${PROG} --moved td08.txt td09.txt
+utilsExec ${PROG} --moved -d td08.txt td09.txt
+utilsExec ${PROG} -Z td10.txt td11.txt
+utilsExec ${PROG} -b --summary td10.txt td11.txt
+utilsExec ${PROG} -W -B --summary td10.txt td11.txt
+utilsExec ${PROG} --ignore-space-change --ignore-blank-lines -d td10.txt td11.txt
+utilsExec ${PROG} -i td12.txt td13.txt
+utilsExec ${PROG} --ignore-case --normalize NFKC --summary td12.txt td13.txt
+utilsExec ${PROG} --normalize nfc -d td12.txt td13.txt
+utilsExec ${PROG} --ignore-lines '^DEBUG' --summary td14.txt td15.txt
+utilsExec ${PROG} --ignore-lines 'start' --ignore-lines 'tick' -d td14.txt td15.txt
+utilsExec ${PROG} -I '^version' -I '^build' --summary td16.txt td17.txt
+utilsExec ${PROG} --ignore-matching-lines '^version' -d --summary td16.txt td17.txt
+utilsExec ${PROG} -I '^version' --ignore-lines '^DEBUG' -d --summary td35.txt td36.txt
+utilsExec ${PROG} --list-masks
+utilsExec ${PROG} --mask timestamps,uuids,hexaddr,pids --summary td18.txt td19.txt
+utilsExec ${PROG} --mask ipv4,ipv6 --mask durations,tmppaths -r "'version [0-9.]+'" "'VERSION'" -d td18.txt td19.txt
+utilsExec ${PROG} --config test.conf --profile jenkins-log --summary td20.txt td21.txt
+utilsExec ${PROG} --config test.conf --profile jenkins-log -d td20.txt td21.txt
+utilsExec ${PROG} --profile jenkins-log --config test.conf -d td20.txt td21.txt
+utilsExec ${PROG} --replace-left "'^load /opt/old/'" "'load /usr/local/'" --mask hexaddr --summary td22.txt td23.txt
+utilsExec ${PROG} --replace-right "'/usr/local/'" "'/opt/old/'" -d td22.txt td23.txt
+utilsExec ${PROG} --config test.conf --profile old-prefix td22.txt td23.txt
+utilsExec ${PROG} -i --config test.conf --profile case-sensitive --summary td12.txt td13.txt
+utilsExec ${PROG} --replace-multiline "'(?s)Traceback.*?\n(\w+Error)'" "'TRACEBACK \$1'" --summary td24.txt td25.txt
+utilsExec ${PROG} --replace-multiline "'(?s)\{\"id\".*?\}'" "'{JSON}'" -d td24.txt td25.txt
+utilsExec ${PROG} --explain-rules --mask timestamps,hexaddr --replace-right "'/tmp/'" "'/TMP/'" td18.txt td19.txt
+utilsExec ${PROG} --explain-rules -d --replace-multiline "'(?s)Traceback.*?\n(\w+Error)'" "'TRACEBACK \$1'" -r "'[0-9]+'" N td24.txt td25.txt
+utilsExec ${PROG} --replace-multiline "'(?s)Traceback.*?\n(\w+Error)'" "'TRACEBACK \$1'" -d --summary td37.txt td38.txt
+utilsExec ${PROG} rules test test.conf
+utilsExec ${PROG} -n --test-rules test.conf
+utilsExec ${PROG} --prefilter sort --summary td08.txt td09.txt
+utilsExec ${PROG} --prefilter-left "'tr a-z A-Z'" --prefilter-right "'cat {}'" -i -d td12.txt td13.txt
+utilsExec ${PROG} --diff3 td26.txt td27.txt td28.txt
+utilsExec ${PROG} --diff3 td26.txt td27.txt td28.txt -d --summary
+utilsExec ${PROG} -c "'lc=fgCyan;rc=fgYellow;cf=bgRed'" -s --diff3 td26.txt td27.txt td28.txt
+utilsExec ${PROG} --take-right -o /tmp/csdiff-merge.txt td01.txt td02.txt
+utilsExec cmp /tmp/csdiff-merge.txt td02.txt
+utilsExec ${PROG} --take-left -o /tmp/csdiff-merge.txt -I "'^infix'" td01.txt td02.txt
+utilsExec cat /tmp/csdiff-merge.txt
+utilsExec rm -f /tmp/csdiff-merge.txt
+utilsExec ${PROG} -R --summary td29 td30
+utilsExec ${PROG} -R -d --mask timestamps td29 td30
+utilsExec ${PROG} -R -d --summary td31 td32
+utilsExec ${PROG} -R --include "'*.c'" --exclude "'lib/'" --summary td31 td32
+utilsExec ${PROG} -R --renames --summary td33 td34
+utilsExec ${PROG} -R --renames --rename-threshold 90 -d td33 td34
+utilsExec ${PROG} -a myers td01.txt td02.txt
+utilsExec ${PROG} -a patience td01.txt td02.txt
+utilsExec ${PROG} --algorithm histogram td03.txt td04.txt
+utilsExec ${PROG} --algorithm histogram -d td02.txt td05.txt
 
 # Print out the 256 color, color tables.
 utilsExec ${PROG} --256